@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kbirk/witch/server"
+)
+
+const (
+	defaultSocket = ".witch.sock"
+	ctlUsage      = "Usage: witch ctl [--socket=<path>] <rerun|pause|resume|status|logs|stop>\n"
+)
+
+var (
+	ctlActions = map[string]string{
+		"rerun":  "run",
+		"pause":  "pause",
+		"resume": "resume",
+		"stop":   "stop",
+	}
+)
+
+// findSocket searches the provided directory and its parents for the control
+// socket of a running witch process. Stale sockets left by a witch process
+// that did not exit cleanly are skipped.
+func findSocket(dir string) (string, error) {
+	for {
+		socket := filepath.Join(dir, defaultSocket)
+		info, err := os.Stat(socket)
+		if err == nil && info.Mode()&os.ModeSocket != 0 && server.SocketInUse(socket) {
+			return socket, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s found, is witch running with --ctl?", defaultSocket)
+		}
+		dir = parent
+	}
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func runCtl(args []string) int {
+	socket := ""
+	tokenSize := 0
+
+	fs := flag.NewFlagSet("ctl", flag.ExitOnError)
+	fs.StringVar(&socket, "socket", "", "Path of the unix control socket, searches the current and parent directories if not provided")
+	fs.IntVar(&tokenSize, "max-token-size", server.DefaultMaxTokenSize, "Max output token size of the logs, in bytes")
+	fs.Usage = func() {
+		os.Stderr.WriteString(ctlUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+
+	if socket == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			os.Stderr.WriteString(fmt.Sprintf("Failed to get working directory: %s\n", err))
+			return 2
+		}
+		socket, err = findSocket(wd)
+		if err != nil {
+			os.Stderr.WriteString(fmt.Sprintf("Failed to find control socket: %s\n", err))
+			return 2
+		}
+	}
+
+	client := server.NewClient(socket)
+	client.MaxTokenSize(tokenSize)
+
+	command := fs.Arg(0)
+	switch command {
+	case "status":
+		status, err := client.Status()
+		if err != nil {
+			os.Stderr.WriteString(fmt.Sprintf("Failed to get status: %s\n", err))
+			return 3
+		}
+		printJSON(status)
+	case "logs":
+		err := client.Stream(func(event server.Event) {
+			if event.Type == "output" {
+				os.Stdout.WriteString(event.Line)
+			}
+		})
+		if err != nil {
+			os.Stderr.WriteString(fmt.Sprintf("Failed to stream logs: %s\n", err))
+			return 3
+		}
+	default:
+		action, ok := ctlActions[command]
+		if !ok {
+			fs.Usage()
+			return 1
+		}
+		err := client.Do(action)
+		if err != nil {
+			os.Stderr.WriteString(fmt.Sprintf("Failed to %s: %s\n", command, err))
+			return 3
+		}
+	}
+	return 0
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+
+	"github.com/kbirk/witch/watcher"
+)
+
+const (
+	// eachPlaceholder is replaced with the quoted path of the changed file.
+	eachPlaceholder = "{}"
+)
+
+var (
+	eachMu = &sync.Mutex{}
+)
+
+// eachResult represents the outcome of running a command against a single
+// file.
+type eachResult struct {
+	path     string
+	err      error
+	timedOut bool
+}
+
+func shellQuote(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
+
+func substitutePath(cmd string, path string) string {
+	if strings.Contains(cmd, eachPlaceholder) {
+		return strings.ReplaceAll(cmd, eachPlaceholder, shellQuote(path))
+	}
+	// no placeholder, append the path as the last argument
+	return fmt.Sprintf("%s %s", cmd, shellQuote(path))
+}
+
+func eachCmdForEvent(event watcher.Event) string {
+	if event.Type == watcher.Removed {
+		// removed files are skipped unless routed to a separate command
+		return eachRemovedCmd
+	}
+	return cmd
+}
+
+func eachResultString(res eachResult) string {
+	if res.err != nil {
+		return fmt.Sprintf("%s %s %s",
+			color.RedString("✘"),
+			color.HiBlackString(res.path),
+			color.RedString(res.err.Error()))
+	}
+	return fmt.Sprintf("%s %s",
+		color.GreenString("✔"),
+		color.HiBlackString(res.path))
+}
+
+func eachSummaryString(succeeded int, failed int) string {
+	return fmt.Sprintf("%s %s %s %s",
+		color.GreenString("%d", succeeded),
+		color.HiBlackString("succeeded,"),
+		color.RedString("%d", failed),
+		color.HiBlackString("failed"))
+}
+
+func runForFile(command string, path string) eachResult {
+	cmdStr := substitutePath(command, path)
+	prettyWriter.WriteStringf("executing %s\n", color.MagentaString(cmdStr))
+
+	// output is prefixed with the path so concurrent files are distinguishable
+	w := taskWriter(path)
+	c := exec.Command("/bin/sh", "-c", cmdStr)
+	c.Env = append(os.Environ(), fmt.Sprintf("WITCH_FILE=%s", path))
+	c.Stdout = w
+	c.Stderr = w
+	// the timeout applies to each file
+	timedOut, err := runTask(c, runTimeout)
+	w.Close()
+	if timedOut {
+		err = fmt.Errorf("timed out after %s", runTimeout)
+	}
+	return eachResult{
+		path:     path,
+		err:      err,
+		timedOut: timedOut,
+	}
+}
+
+func executeEach(events []watcher.Event) {
+	// batches are run one at a time, in the order they are detected
+	eachMu.Lock()
+	defer eachMu.Unlock()
+
+	jobs := numJobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	queue := make(chan watcher.Event)
+	results := make(chan eachResult)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for event := range queue {
+				results <- runForFile(eachCmdForEvent(event), event.Path)
+			}
+		}()
+	}
+
+	skipped := 0
+	go func() {
+		for _, event := range events {
+			if eachCmdForEvent(event) == "" {
+				skipped++
+				continue
+			}
+			queue <- event
+		}
+		close(queue)
+		wg.Wait()
+		close(results)
+	}()
+
+	succeeded := 0
+	failed := 0
+	timedOut := 0
+	for res := range results {
+		prettyWriter.WriteStringf("%s\n", eachResultString(res))
+		if res.err != nil {
+			failed++
+		} else {
+			succeeded++
+		}
+		if res.timedOut {
+			timedOut++
+		}
+	}
+
+	if skipped > 0 {
+		prettyWriter.WriteStringf("skipped %s\n", color.BlueString("%d removed", skipped))
+	}
+	prettyWriter.WriteStringf("%s\n", eachSummaryString(succeeded, failed))
+
+	code := 0
+	if timedOut > 0 {
+		code = timeoutExitCode
+	} else if failed > 0 {
+		code = 1
+	}
+	if stopOnNonZero && code != 0 {
+		prettyWriter.WriteStringf("exiting due to %s\n", color.RedString("%d failed", failed))
+		requestExit(code)
+		return
+	}
+	runCompleted(code)
+
+	// reload browsers after a successful batch
+	if failed == 0 && succeeded > 0 {
+		reloadBrowsers(events)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+const (
+	// timeoutExitCode matches the exit code of coreutils `timeout`.
+	timeoutExitCode = 124
+)
+
+var (
+	once          bool
+	noInitialRun  bool
+	exitAfter     int
+	completedRuns int
+	exitMu        = &sync.Mutex{}
+	exits         = make(chan int, 1)
+)
+
+// exitStatus returns the exit code witch should exit with after the run.
+func exitStatus(r *run) int {
+	switch {
+	case r.exit.timedOut:
+		return timeoutExitCode
+	case r.signal != 0:
+		// killed by a signal, as reported by shells
+		return 128 + int(r.signal)
+	case r.exitCode < 0:
+		return 1
+	}
+	return r.exitCode
+}
+
+// requestExit asks the scan loop to shutdown with the provided exit code.
+func requestExit(code int) {
+	select {
+	case exits <- code:
+	default:
+		// an exit is already pending
+	}
+}
+
+// runCompleted counts a completed run, exiting once the requested number of
+// runs have completed.
+func runCompleted(code int) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	completedRuns++
+	if exitAfter > 0 && completedRuns >= exitAfter {
+		runs := "runs"
+		if completedRuns == 1 {
+			runs = "run"
+		}
+		prettyWriter.WriteStringf("exiting after %s %s with code %s\n",
+			color.BlueString("%d", completedRuns),
+			runs,
+			color.BlueString("%d", code))
+		requestExit(code)
+	}
+}
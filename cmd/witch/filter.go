@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/kbirk/witch/writer"
+)
+
+var (
+	outputExcludes  patternsFlag
+	outputIncludes  patternsFlag
+	outputRules     *writer.Rules
+	highlights      patternsFlag
+	highlightColors = map[string]color.Attribute{
+		"red":     color.FgRed,
+		"green":   color.FgGreen,
+		"yellow":  color.FgYellow,
+		"blue":    color.FgBlue,
+		"magenta": color.FgMagenta,
+		"cyan":    color.FgCyan,
+		"white":   color.FgWhite,
+	}
+)
+
+// patternsFlag represents a flag which may be provided multiple times.
+type patternsFlag []string
+
+func (f *patternsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *patternsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// parseHighlight parses a highlight of the form `[color:]pattern`, defaulting
+// to red if no color is provided.
+func parseHighlight(arg string) (*regexp.Regexp, color.Attribute, error) {
+	attr := color.FgRed
+	pattern := arg
+	index := strings.IndexByte(arg, ':')
+	if index != -1 {
+		if c, ok := highlightColors[arg[:index]]; ok {
+			attr = c
+			pattern = arg[index+1:]
+		}
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, 0, err
+	}
+	return re, attr, nil
+}
+
+func parseOutputRules() (*writer.Rules, error) {
+	rules := &writer.Rules{}
+	for _, arg := range outputExcludes {
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("`--output-exclude` argument: %s", err)
+		}
+		rules.Exclude(re)
+	}
+	for _, arg := range outputIncludes {
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("`--output-include` argument: %s", err)
+		}
+		rules.Include(re)
+	}
+	for _, arg := range highlights {
+		re, attr, err := parseHighlight(arg)
+		if err != nil {
+			return nil, fmt.Errorf("`--highlight` argument: %s", err)
+		}
+		rules.Highlight(re, attr)
+	}
+	if rules.Empty() {
+		return nil, nil
+	}
+	return rules, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/kbirk/witch/fingerprint"
+	"github.com/kbirk/witch/watcher"
+)
+
+var (
+	skipUnchanged    bool
+	inputs           *fingerprint.Set
+	inputsMu         = &sync.Mutex{}
+	successfulInputs = make(map[string]string)
+)
+
+// setupInputs hashes the contents of every watched file.
+func setupInputs(w *watcher.Watcher) error {
+	targets, err := w.Targets()
+	if err != nil {
+		return err
+	}
+	set := fingerprint.New()
+	for _, target := range targets {
+		err := set.Update(target)
+		if err != nil {
+			return err
+		}
+	}
+	inputs = set
+	return nil
+}
+
+func updateInputs(event watcher.Event) {
+	if inputs == nil {
+		return
+	}
+	if event.Type == watcher.Removed {
+		inputs.Remove(event.Path)
+		return
+	}
+	err := inputs.Update(event.Path)
+	if err != nil {
+		// removed before it could be hashed
+		inputs.Remove(event.Path)
+	}
+}
+
+func currentInputs() string {
+	if inputs == nil {
+		return ""
+	}
+	return inputs.Sum()
+}
+
+// inputsUnchanged returns whether or not the inputs match those of the last
+// successful run of the command. A running command is never skipped, as it
+// may have been started with different inputs.
+func inputsUnchanged(command string) bool {
+	if inputs == nil {
+		return false
+	}
+	mu.Lock()
+	running := prev != nil
+	mu.Unlock()
+	if running {
+		return false
+	}
+
+	inputsMu.Lock()
+	defer inputsMu.Unlock()
+	sum, ok := successfulInputs[command]
+	return ok && sum == inputs.Sum()
+}
+
+func recordInputs(r *run) {
+	if r.inputs == "" || r.outcome() != "success" {
+		return
+	}
+	inputsMu.Lock()
+	defer inputsMu.Unlock()
+	successfulInputs[r.command] = r.inputs
+}
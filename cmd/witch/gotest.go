@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/kbirk/witch/gotest"
+	"github.com/kbirk/witch/watcher"
+	"github.com/kbirk/witch/writer"
+)
+
+const (
+	goTestMatchers = "go,gotest"
+)
+
+var (
+	goTest         bool
+	goTestFlags    string
+	goTestRenderer *gotest.Renderer
+)
+
+func goTestCmdString(pkgs []string) string {
+	args := []string{"go", "test", "-json"}
+	if goTestFlags != "" {
+		args = append(args, goTestFlags)
+	}
+	for _, pkg := range pkgs {
+		args = append(args, shellQuote(pkg))
+	}
+	return strings.Join(args, " ")
+}
+
+func setupGoTest() {
+	cmd = goTestCmdString([]string{"./..."})
+	if problemsStr == "" {
+		problemsStr = goTestMatchers
+	}
+	goTestRenderer = gotest.NewRenderer()
+	cmdWriter.Transform(goTestRenderer.Render)
+}
+
+// affectedCmd returns the go test cmd for the packages affected by the
+// events, or false if no packages are affected.
+func affectedCmd(events []watcher.Event) (string, bool) {
+	graph, err := gotest.Load(".", "./...")
+	if err != nil {
+		prettyWriter.WriteErrorf("failed to list packages, testing all: %s\n", err)
+		return cmd, true
+	}
+	pkgs := graph.Affected(eventPaths(events))
+	if len(pkgs) == 0 {
+		return "", false
+	}
+	return goTestCmdString(pkgs), true
+}
+
+func resetGoTest() {
+	if goTestRenderer != nil {
+		goTestRenderer.Reset()
+	}
+}
+
+func goTestSummaryString(s gotest.Summary) string {
+	failed := color.HiBlackString("%d failed,", s.Failed)
+	if s.Failed > 0 {
+		failed = color.RedString("%d failed,", s.Failed)
+	}
+	packages := "packages"
+	if s.Packages == 1 {
+		packages = "package"
+	}
+	return fmt.Sprintf("%s %s %s %s",
+		color.GreenString("%d passed,", s.Passed),
+		failed,
+		color.YellowString("%d skipped", s.Skipped),
+		color.HiBlackString("in %d %s", s.Packages, packages))
+}
+
+// reportGoTest summarizes the tests of the run.
+func reportGoTest(r *run) {
+	if goTestRenderer == nil || r.exit.killed {
+		return
+	}
+	s := goTestRenderer.Summary()
+	prettyWriter.WriteEntryf(writer.Entry{
+		Type: "test_summary",
+	}, "%s\n", goTestSummaryString(s))
+	for _, failure := range s.Failures {
+		prettyWriter.WriteStringf("%s %s\n", color.RedString("✘"), failure)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/kbirk/witch/history"
+)
+
+const (
+	historyUsage      = "Usage: witch history [--state-dir=<path>] [--limit=<count>] [show <id|last|failed>]\n"
+	defaultListedRuns = 20
+	maxListedTriggers = 3
+)
+
+var (
+	stateDir       string
+	noHistory      bool
+	historyMaxRuns int
+	runHistory     *history.Store
+)
+
+func openHistory(dir string) (*history.Store, error) {
+	if dir == "" {
+		var err error
+		dir, err = history.DefaultDir(".")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return history.Open(dir)
+}
+
+func recordRun(r *run) {
+	if runHistory == nil {
+		return
+	}
+	events := make([]history.Event, 0, len(r.events))
+	for _, event := range r.events {
+		events = append(events, history.Event{
+			Type: event.Type.String(),
+			Path: event.Path,
+		})
+	}
+	_, err := runHistory.Append(history.Record{
+		Cmd:        r.command,
+		Start:      r.start,
+		End:        r.start.Add(r.duration),
+		DurationMs: r.duration.Milliseconds(),
+		ExitCode:   r.exitCode,
+		Outcome:    r.outcome(),
+		Events:     events,
+		Output:     outputTail.String(),
+	})
+	if err != nil {
+		prettyWriter.WriteErrorf("failed to record run: %s\n", err)
+	}
+}
+
+func outcomeString(rec history.Record) string {
+	switch rec.Outcome {
+	case "success":
+		return color.GreenString("✔ %d", rec.ExitCode)
+	case "killed":
+		return color.YellowString("killed")
+	case "timeout":
+		return color.RedString("✘ timeout")
+	}
+	return color.RedString("✘ %d", rec.ExitCode)
+}
+
+func triggersString(rec history.Record) string {
+	if len(rec.Events) == 0 {
+		return color.HiBlackString("-")
+	}
+	var paths []string
+	for i, event := range rec.Events {
+		if i == maxListedTriggers {
+			break
+		}
+		paths = append(paths, event.Path)
+	}
+	res := strings.Join(paths, ", ")
+	if len(rec.Events) > maxListedTriggers {
+		res += fmt.Sprintf(" (+%d)", len(rec.Events)-maxListedTriggers)
+	}
+	return color.HiBlackString(res)
+}
+
+func recordString(rec history.Record) string {
+	duration := time.Duration(rec.DurationMs) * time.Millisecond
+	return fmt.Sprintf("%s %s %s %s %s",
+		color.MagentaString("#%-4d", rec.ID),
+		color.HiBlackString(rec.Start.Local().Format(time.Stamp)),
+		color.BlueString("%8s", duration.Round(time.Millisecond)),
+		outcomeString(rec),
+		triggersString(rec))
+}
+
+func printRecord(rec history.Record) {
+	fmt.Printf("%s\n", recordString(rec))
+	fmt.Printf("%s %s\n", color.HiBlackString("cmd:"), color.MagentaString(rec.Cmd))
+	for _, event := range rec.Events {
+		fmt.Printf("%s %s %s\n", color.HiBlackString("trigger:"), event.Path, color.HiBlackString(event.Type))
+	}
+	if rec.Output != "" {
+		fmt.Printf("%s\n%s", color.HiBlackString("output:"), rec.Output)
+		if !strings.HasSuffix(rec.Output, "\n") {
+			fmt.Println()
+		}
+	}
+}
+
+func findRecord(store *history.Store, arg string) (history.Record, error) {
+	switch arg {
+	case "failed":
+		return store.LastFailed()
+	case "last":
+		records, err := store.Tail(1)
+		if err != nil {
+			return history.Record{}, err
+		}
+		if len(records) == 0 {
+			return history.Record{}, fmt.Errorf("no runs recorded")
+		}
+		return records[len(records)-1], nil
+	}
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return history.Record{}, fmt.Errorf("invalid run id `%s`", arg)
+	}
+	return store.Get(id)
+}
+
+func runHistoryCmd(args []string) int {
+	dir := ""
+	limit := 0
+
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.StringVar(&dir, "state-dir", "", "Directory the run history is stored in, defaults to a per project directory in the user cache directory")
+	fs.IntVar(&limit, "limit", defaultListedRuns, "Max number of the most recent runs to list, all retained runs if 0")
+	fs.Usage = func() {
+		os.Stderr.WriteString(historyUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	store, err := openHistory(dir)
+	if err != nil {
+		os.Stderr.WriteString(fmt.Sprintf("Failed to open history: %s\n", err))
+		return 2
+	}
+
+	switch {
+	case fs.NArg() == 0:
+		var records []history.Record
+		if limit > 0 {
+			records, err = store.Tail(limit)
+		} else {
+			records, err = store.List()
+		}
+		if err != nil {
+			os.Stderr.WriteString(fmt.Sprintf("Failed to read history: %s\n", err))
+			return 3
+		}
+		for _, rec := range records {
+			fmt.Printf("%s\n", recordString(rec))
+		}
+	case fs.NArg() == 2 && fs.Arg(0) == "show":
+		rec, err := findRecord(store, fs.Arg(1))
+		if err != nil {
+			os.Stderr.WriteString(fmt.Sprintf("Failed to find run: %s\n", err))
+			return 3
+		}
+		printRecord(rec)
+	default:
+		fs.Usage()
+		return 1
+	}
+	return 0
+}
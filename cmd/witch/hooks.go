@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+var (
+	preRunCmd         string
+	postRunCmd        string
+	onSuccessCmd      string
+	onFailureCmd      string
+	onFirstFailureCmd string
+	onRecoveryCmd     string
+	prevSucceeded     *bool
+)
+
+func runHook(hook string, command string, env []string) {
+	if command == "" {
+		return
+	}
+	prettyWriter.WriteStringf("executing %s %s\n",
+		color.HiBlackString(hook),
+		color.MagentaString(command))
+	err := runShell(hook, command, env)
+	if err != nil {
+		prettyWriter.WriteErrorf("%s hook failed: %s\n", hook, err)
+	}
+}
+
+func preRunEnv(r *run) []string {
+	return []string{
+		fmt.Sprintf("WITCH_FILES=%s", strings.Join(eventPaths(r.events), "\n")),
+	}
+}
+
+func postRunEnv(r *run) []string {
+	return append(preRunEnv(r),
+		fmt.Sprintf("WITCH_OUTCOME=%s", r.outcome()),
+		fmt.Sprintf("WITCH_EXIT_CODE=%d", r.exitCode),
+		fmt.Sprintf("WITCH_DURATION=%s", r.duration),
+		fmt.Sprintf("WITCH_DURATION_MS=%d", r.duration.Milliseconds()))
+}
+
+func executePreRunHook(r *run) {
+	runHook("pre-run", preRunCmd, preRunEnv(r))
+}
+
+// postRunHooks returns the hooks to run once the run exits, in order,
+// tracking whether or not the run succeeded for the transition hooks.
+func postRunHooks(r *run) []string {
+	hooks := []string{"post-run"}
+
+	if r.exit.killed {
+		// runs interrupted by witch neither succeed nor fail
+		return hooks
+	}
+
+	succeeded := r.outcome() == "success"
+	if succeeded {
+		hooks = append(hooks, "on-success")
+		if prevSucceeded != nil && !*prevSucceeded {
+			hooks = append(hooks, "on-recovery")
+		}
+	} else {
+		hooks = append(hooks, "on-failure")
+		if prevSucceeded != nil && *prevSucceeded {
+			hooks = append(hooks, "on-first-failure")
+		}
+	}
+	prevSucceeded = &succeeded
+	return hooks
+}
+
+func postRunHookCmd(hook string) string {
+	switch hook {
+	case "post-run":
+		return postRunCmd
+	case "on-success":
+		return onSuccessCmd
+	case "on-recovery":
+		return onRecoveryCmd
+	case "on-failure":
+		return onFailureCmd
+	case "on-first-failure":
+		return onFirstFailureCmd
+	}
+	return ""
+}
+
+func executePostRunHooks(r *run) {
+	env := postRunEnv(r)
+	for _, hook := range postRunHooks(r) {
+		runHook(hook, postRunHookCmd(hook), env)
+	}
+}
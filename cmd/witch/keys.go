@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/fatih/color"
+
+	"github.com/kbirk/witch/tty"
+	"github.com/kbirk/witch/watcher"
+)
+
+// action represents a request to the scan loop.
+type action int
+
+const (
+	rerunAction action = iota
+	killAction
+	togglePauseAction
+	pauseAction
+	resumeAction
+	restartAction
+	clearAction
+	listAction
+	helpAction
+	quitAction
+)
+
+var (
+	noKeys  bool
+	paused  bool
+	actions = make(chan action, 16)
+	keys    = []struct {
+		key         byte
+		action      action
+		description string
+	}{
+		{'r', rerunAction, "rerun cmd"},
+		{'k', killAction, "kill cmd"},
+		{'p', togglePauseAction, "pause / resume watching"},
+		{'c', clearAction, "clear screen and scrollback"},
+		{'l', listAction, "list watched files"},
+		{'?', helpAction, "show help"},
+		{'q', quitAction, "quit"},
+	}
+)
+
+func keyAction(key byte) (action, bool) {
+	for _, k := range keys {
+		if k.key == key {
+			return k.action, true
+		}
+	}
+	return 0, false
+}
+
+func helpString() string {
+	res := ""
+	for _, k := range keys {
+		res += fmt.Sprintf("  %s %s\n",
+			color.MagentaString("%c", k.key),
+			color.HiBlackString(k.description))
+	}
+	return res
+}
+
+func readKeys() error {
+	state, err := tty.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	terminalState = state
+
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			_, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			a, ok := keyAction(buf[0])
+			if ok {
+				actions <- a
+			}
+		}
+	}()
+	return nil
+}
+
+func listTargets(w *watcher.Watcher) {
+	targets, err := w.Targets()
+	if err != nil {
+		prettyWriter.WriteErrorf("failed to list watched files: %s\n", err)
+		return
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		prettyWriter.WriteStringf("%s\n", color.BlueString(target))
+	}
+	prettyWriter.WriteStringf("%s\n", fileCountString(uint64(len(targets))))
+}
+
+func setPaused(p bool) {
+	if paused == p {
+		return
+	}
+	paused = p
+	spin.Pause(paused)
+	if paused {
+		prettyWriter.WriteStringf("watching %s\n", color.YellowString("paused"))
+	} else {
+		prettyWriter.WriteStringf("watching %s\n", color.GreenString("resumed"))
+	}
+	onPause(paused)
+}
+
+func handleAction(a action, w *watcher.Watcher) {
+	switch a {
+	case rerunAction:
+		if cmd == "" || each {
+			prettyWriter.WriteStringf("no cmd to rerun\n")
+			return
+		}
+		cancelRestart(true)
+		err := executeCmd(cmd, nil)
+		if err != nil {
+			prettyWriter.WriteErrorf("failed to run cmd: %s\n", err)
+		}
+	case restartAction:
+		mu.Lock()
+		running := prev != nil
+		mu.Unlock()
+		if running {
+			// already restarted by a change
+			return
+		}
+		err := executeCmd(cmd, nil)
+		if err != nil {
+			prettyWriter.WriteErrorf("failed to run cmd: %s\n", err)
+		}
+	case killAction:
+		cancelRestart(true)
+		killCmd()
+	case togglePauseAction:
+		setPaused(!paused)
+	case pauseAction:
+		setPaused(true)
+	case resumeAction:
+		setPaused(false)
+	case clearAction:
+		console.Clear()
+	case listAction:
+		listTargets(w)
+	case helpAction:
+		prettyWriter.WriteStringf("keys:\n%s", helpString())
+	case quitAction:
+		shutdown(0)
+	}
+}
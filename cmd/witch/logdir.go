@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/kbirk/witch/runlog"
+)
+
+var (
+	logDir       string
+	logMaxFiles  int
+	logMaxBytes  int64
+	logStripANSI bool
+	runLogs      *runlog.Dir
+)
+
+func openRunLogs() error {
+	d, err := runlog.Open(logDir)
+	if err != nil {
+		return err
+	}
+	d.MaxFiles(logMaxFiles)
+	d.MaxBytes(logMaxBytes)
+	d.StripANSI(logStripANSI)
+	cmdWriter.AddSink(d)
+	runLogs = d
+	return nil
+}
+
+func startRunLog(r *run) {
+	if runLogs == nil {
+		return
+	}
+	_, err := runLogs.Start(r.start)
+	if err != nil {
+		prettyWriter.WriteErrorf("failed to start run log: %s\n", err)
+	}
+}
+
+func stopRunLog() {
+	if runLogs == nil {
+		return
+	}
+	err := runLogs.Stop()
+	if err != nil {
+		prettyWriter.WriteErrorf("failed to stop run log: %s\n", err)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/kbirk/witch/writer"
+)
+
+func setLogFormat(format writer.Format) {
+	logFormat = format
+	prettyWriter.SetFormat(format)
+	cmdWriter.SetFormat(format)
+	if format == writer.JSONFormat {
+		// the spinner overwrites lines, which json consumers can't handle
+		noSpinner = true
+	}
+}
+
+func logRunExit(r *run) {
+	exitCode := r.exitCode
+	prettyWriter.WriteEntry(writer.Entry{
+		Type:       "run_exit",
+		Cmd:        r.command,
+		ExitCode:   &exitCode,
+		DurationMs: r.duration.Milliseconds(),
+		Message:    r.outcome(),
+	})
+}
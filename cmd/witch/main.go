@@ -5,15 +5,17 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
-	"github.com/creack/pty"
 	"github.com/fatih/color"
 
 	"github.com/kbirk/witch/graceful"
+	"github.com/kbirk/witch/history"
+	"github.com/kbirk/witch/server"
 	"github.com/kbirk/witch/spinner"
 	"github.com/kbirk/witch/watcher"
 	"github.com/kbirk/witch/writer"
@@ -22,23 +24,40 @@ import (
 const (
 	name    = "witch"
 	version = "0.2.13"
+
+	outputDrainTimeout = time.Second
 )
 
 var (
-	watch         []string
-	ignore        []string
-	cmd           string
-	watchInterval int
-	noSpinner     bool
-	stopOnNonZero bool
-	maxTokenSize  int
-	tickInterval  = 100
-	prev          *exec.Cmd
-	ready         = make(chan bool, 1)
-	mu            = &sync.Mutex{}
-	prettyWriter  = writer.NewPretty(name, os.Stdout)
-	cmdWriter     = writer.NewCmd(name, os.Stdout)
-	spin          = spinner.New(prettyWriter)
+	watch          []string
+	ignore         []string
+	cmd            string
+	watchInterval  int
+	noSpinner      bool
+	stopOnNonZero  bool
+	runTimeout     time.Duration
+	maxTokenSize   int
+	each           bool
+	numJobs        int
+	eachRemovedCmd string
+	onAddedCmd     string
+	onChangedCmd   string
+	onRemovedCmd   string
+	eventTypes     map[watcher.EventType]bool
+	noPty          bool
+	tintStderr     bool
+	passThrough    bool
+	usePty         bool
+	logFormat      writer.Format
+	tickInterval   = 100
+	prev           *run
+	ready          = make(chan bool, 1)
+	mu             = &sync.Mutex{}
+	console        = writer.NewConsole(os.Stdout)
+	prettyWriter   = writer.NewPretty(name, console)
+	cmdWriter      = writer.NewCmd(name, console)
+	outputTail     = newTail(maxTailLines)
+	spin           = spinner.New(prettyWriter)
 )
 
 func createLogo() string {
@@ -93,89 +112,375 @@ func splitAndTrim(arg string) []string {
 	return res
 }
 
+// run represents a single execution of the cmd.
+type run struct {
+	command  string
+	cmd      *exec.Cmd
+	events   []watcher.Event
+	pty      *os.File
+	start    time.Time
+	duration time.Duration
+	exitCode int
+	// the signal that killed the cmd, if any
+	signal syscall.Signal
+	// flagged under mu while the cmd is running
+	killed     bool
+	timedOut   bool
+	exit       runExit
+	timer      *time.Timer
+	stopProbes func()
+	inputs     string
+}
+
+// runExit represents how a run ended. The flags of the run are set under mu
+// while it is running, so they are snapshot once the cmd exits and only the
+// snapshot is read afterwards.
+type runExit struct {
+	killed   bool
+	timedOut bool
+}
+
+// outcome returns a description of how the run ended.
+func (r *run) outcome() string {
+	switch {
+	case r.exit.killed:
+		return "killed"
+	case r.exit.timedOut:
+		return "timeout"
+	case r.exitCode == 0:
+		return "success"
+	}
+	return "failure"
+}
+
+func killProcess(r *run) {
+	// flush any pending output
+	cmdWriter.Flush()
+	// send kill signal
+	err := syscall.Kill(-r.cmd.Process.Pid, syscall.SIGKILL)
+	if err != nil {
+		prettyWriter.WriteErrorf("failed to kill prev running cmd: %s\n", err)
+	}
+}
+
 func killCmd() {
 	mu.Lock()
 	if prev != nil {
-		// flush any pending output
-		cmdWriter.Flush()
-		// send kill signal
-		err := syscall.Kill(-prev.Process.Pid, syscall.SIGKILL)
-		if err != nil {
-			prettyWriter.WriteStringf("failed to kill prev running cmd: %s\n", err)
-		}
+		// flag that the exit was caused by witch
+		prev.killed = true
+		killProcess(prev)
 	}
 	mu.Unlock()
 }
 
-func executeCmd(cmd string) error {
+func timeoutCmd(r *run) {
+	mu.Lock()
+	defer mu.Unlock()
+	if prev != r {
+		// already exited
+		return
+	}
+	r.timedOut = true
+	prettyWriter.WriteStringf("cmd %s after %s\n",
+		color.RedString("timed out"),
+		color.BlueString(runTimeout.String()))
+	killProcess(r)
+}
+
+func startWithPipes(c *exec.Cmd) error {
+	// run in a new process group so that the entire group can be killed
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := c.StderrPipe()
+	if err != nil {
+		return err
+	}
+	err = c.Start()
+	if err != nil {
+		return err
+	}
+	// proxy the output to the cmd writer
+	cmdWriter.ProxyStreams(stdout, stderr)
+	return nil
+}
+
+func startCmd(r *run) error {
+	if !usePty {
+		return startWithPipes(r.cmd)
+	}
+	f, err := startPty(r)
+	if err != nil {
+		return err
+	}
+	r.pty = f
+	// proxy the output to the cmd writer
+	cmdWriter.Proxy(f)
+	return nil
+}
+
+func executeCmd(cmd string, events []watcher.Event) error {
+	// hold proxied requests until the new process is up
+	proxyRestart()
+
 	// kill prev process
 	killCmd()
 
 	// wait until ready
 	<-ready
 
+	// clear output of prev process
+	outputTail.Reset()
+	resetProblems()
+	resetGoTest()
+	beginRun()
+
 	// create command
 	c := exec.Command("/bin/sh", "-c", cmd)
+	r := &run{
+		command: cmd,
+		cmd:     c,
+		events:  events,
+		inputs:  currentInputs(),
+	}
+
+	// run pre run hook
+	executePreRunHook(r)
 
 	// log cmd
-	prettyWriter.WriteStringf("executing %s\n", color.MagentaString(cmd))
+	prettyWriter.WriteEntryf(writer.Entry{
+		Type: "run_start",
+		Cmd:  cmd,
+	}, "executing %s\n", color.MagentaString(cmd))
 
 	// run command in another process
-	f, err := pty.Start(c)
+	r.start = time.Now()
+	startRunLog(r)
+	resetProbes()
+	err := startCmd(r)
 	if err != nil {
+		// flag we are ready
+		ready <- true
 		return err
 	}
+	onRunStart(r)
 
-	// proxy the output to the cmd writer
-	cmdWriter.Proxy(f)
+	// probe whether the process is ready
+	if len(probes) > 0 {
+		awaitReady(r)
+	}
+
+	// kill the process if it runs too long
+	if runTimeout > 0 {
+		r.timer = time.AfterFunc(runTimeout, func() {
+			timeoutCmd(r)
+		})
+	}
 
 	// wait on process
 	go func() {
 		state, err := c.Process.Wait()
-
-		// check exit code
-		if stopOnNonZero && state.ExitCode() != 0 {
-			if stopOnNonZero {
-				prettyWriter.WriteStringf("exiting due to non-zero error code: %d\n", state.ExitCode())
-				os.Exit(3)
+		r.duration = time.Since(r.start)
+		r.exitCode = -1
+		if state != nil {
+			r.exitCode = state.ExitCode()
+			if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				r.signal = status.Signal()
 			}
 		}
 
-		if err != nil {
-			prettyWriter.WriteStringf("cmd encountered error: %s\n", err)
+		// snapshot how the run ended
+		mu.Lock()
+		r.exit = runExit{
+			killed:   r.killed,
+			timedOut: r.timedOut,
+		}
+		mu.Unlock()
+
+		// stop probing the exited process
+		if r.stopProbes != nil {
+			r.stopProbes()
+		}
+		exitReadyState(r)
+
+		// let any remaining output drain
+		cmdWriter.Wait(outputDrainTimeout)
+		cmdWriter.Flush()
+		stopRunLog()
+
+		// stop the timeout
+		if r.timer != nil {
+			r.timer.Stop()
 		}
 
 		// clear prev
 		mu.Lock()
 		prev = nil
 		mu.Unlock()
+
+		// summarize the run
+		reportGoTest(r)
+		reportProblems(r)
+		endRun(r)
+
+		if err != nil {
+			prettyWriter.WriteErrorf("cmd encountered error: %s\n", err)
+		}
+
+		onRunExit(r)
+		logRunExit(r)
+		recordInputs(r)
+		proxyExit(r)
+		recordRun(r)
+
+		// run post run hooks
+		executePostRunHooks(r)
+
+		// reload browsers after a successful run
+		if !r.exit.killed && r.exitCode == 0 {
+			reloadBrowsers(r.events)
+		}
+
 		// flag we are ready
 		ready <- true
+
+		if !r.exit.killed {
+			// check exit code, once all of the exit bookkeeping is done
+			if stopOnNonZero && (r.exit.timedOut || r.exitCode != 0) {
+				if r.exit.timedOut {
+					prettyWriter.WriteStringf("exiting due to timeout\n")
+				} else {
+					prettyWriter.WriteStringf("exiting due to non-zero error code: %d\n", r.exitCode)
+				}
+				requestExit(exitStatus(r))
+				return
+			}
+			runCompleted(exitStatus(r))
+		}
+
+		// restart if it exited on its own
+		superviseExit(r)
 	}()
 
 	// store process
 	mu.Lock()
-	prev = c
+	prev = r
 	mu.Unlock()
 	return nil
 }
 
+func shutdown(code int) {
+	// kill process
+	killCmd()
+	// kill any hooks and per file cmds
+	killTasks()
+	cmdWriter.Flush()
+	stopServer()
+	stopLiveReload()
+	stopProxy()
+	spin.Done()
+	restoreTerminal()
+	os.Exit(code)
+}
+
 func main() {
 
+	// run the control client
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		os.Exit(runCtl(os.Args[2:]))
+	}
+
+	// inspect the run history
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		os.Exit(runHistoryCmd(os.Args[2:]))
+	}
+
 	watchStr := ""
 	ignoreStr := ""
+	logFormatStr := ""
+	eventsStr := ""
 
 	flag.StringVar(&cmd, "cmd", "", "Shell command to run after detected changes")
 	flag.StringVar(&watchStr, "watch", ".", "Comma separated file and directory globs to watch")
 	flag.StringVar(&ignoreStr, "ignore", "", "Comma separated file and directory globs to ignore")
 	flag.IntVar(&watchInterval, "interval", 400, "Watch scan interval, in milliseconds")
-	flag.IntVar(&maxTokenSize, "max-token-size", 1024*1000*2, "Max output token size, in bytes")
+	flag.IntVar(&maxTokenSize, "max-token-size", server.DefaultMaxTokenSize, "Max output token size, in bytes")
 	flag.BoolVar(&noSpinner, "no-spinner", false, "Disable fancy terminal spinner")
-	flag.BoolVar(&stopOnNonZero, "stop-on-nonzero", false, "Stop witch process if the provided cmd returns a non-zero exit code")
+	flag.StringVar(&logFormatStr, "log-format", "text", "Format of the logged output, one of text or json")
+	flag.BoolVar(&stopOnNonZero, "stop-on-nonzero", false, "Stop witch process with the exit code of the provided cmd if it returns a non-zero exit code")
+	flag.BoolVar(&each, "each", false, "Run the cmd once per changed file, substituting the path for {}")
+	flag.IntVar(&numJobs, "jobs", runtime.NumCPU(), "Max number of concurrent per file cmds when using --each")
+	flag.StringVar(&eachRemovedCmd, "each-removed", "", "Shell command to run per removed file when using --each, removed files are skipped if not provided")
+	flag.StringVar(&onAddedCmd, "on-added", "", "Shell command to run instead of the cmd when files are added")
+	flag.StringVar(&onChangedCmd, "on-changed", "", "Shell command to run instead of the cmd when files are changed")
+	flag.StringVar(&onRemovedCmd, "on-removed", "", "Shell command to run instead of the cmd when files are removed")
+	flag.BoolVar(&noPty, "no-pty", false, "Run the cmd with separate stdout and stderr pipes instead of a pseudo terminal, this is the default if stdout is not a terminal")
+	flag.BoolVar(&tintStderr, "tint-stderr", false, "Color stderr output of the cmd red, only applies when run without a pseudo terminal")
+	flag.BoolVar(&groupOutput, "group-output", false, "Write the output of each run of the cmd, hook and per file cmd as a single block once it completes instead of streaming it")
+	flag.BoolVar(&clearScreen, "clear", false, "Clear the screen and scrollback before each run of the cmd")
+	flag.BoolVar(&noSeparator, "no-separator", false, "Disable the separator and summary written around each run of the cmd")
+	flag.Var(&outputExcludes, "output-exclude", "Regular expression of cmd output lines to hide, may be provided multiple times")
+	flag.Var(&outputIncludes, "output-include", "Regular expression of cmd output lines to show, hiding all others, may be provided multiple times")
+	flag.Var(&highlights, "highlight", "Regular expression of cmd output lines to highlight, optionally prefixed with a color such as yellow:warning, may be provided multiple times")
+	flag.BoolVar(&goTest, "go-test", false, "Run go test on the packages affected by the changed files instead of the cmd, summarizing the results")
+	flag.StringVar(&goTestFlags, "go-test-flags", "", "Additional flags passed to go test when using --go-test")
+	flag.StringVar(&problemsStr, "problems", "", "Comma separated problem matchers extracting diagnostics from the cmd output, any of go, gotest, tsc, eslint or gcc")
+	flag.Var(&problemPatterns, "problem-pattern", "Regular expression extracting diagnostics from the cmd output, with the named groups file, line and optionally col, severity and message, may be provided multiple times")
+	flag.StringVar(&errorfile, "errorfile", "", "Path to write the diagnostics found by the problem matchers to after each run, in the errorformat used by vim and emacs")
+	flag.BoolVar(&skipUnchanged, "skip-unchanged", false, "Skip running the cmd after changes if the contents of the watched files match those of its last successful run")
+	flag.BoolVar(&passThrough, "passthrough", false, "Forward partial lines and carriage return updates of the cmd output immediately, such as progress bars and prompts")
+	flag.BoolVar(&interactive, "interactive", false, "Forward terminal input to the cmd, requires a pseudo terminal")
+	flag.BoolVar(&noKeys, "no-keys", false, "Disable keyboard controls")
+	flag.StringVar(&httpAddr, "http", "", "Address to serve the http status and control api on, prefix with unix: to serve on a unix socket")
+	flag.BoolVar(&serveCtl, "ctl", false, "Serve the unix control socket used by witch ctl")
+	flag.StringVar(&socketPath, "socket", defaultSocket, "Path of the unix control socket served with --ctl")
+	flag.StringVar(&liveReloadAddr, "livereload", "", "Address to serve the browser live reload script on, browsers reload after the cmd succeeds")
+	flag.StringVar(&proxyAddr, "proxy", "", "Address to serve a reverse proxy to the cmd on, requests are held while the cmd restarts")
+	flag.StringVar(&proxyTarget, "proxy-target", "", "Address the cmd listens on, requires --proxy")
+	flag.StringVar(&readyTCP, "ready-tcp", "", "Address the cmd is ready once it accepts connections on")
+	flag.StringVar(&readyHTTP, "ready-http", "", "URL the cmd is ready once it responds to with a 200 status")
+	flag.StringVar(&readyPattern, "ready-pattern", "", "Regular expression the cmd is ready once a line of its output matches")
+	flag.DurationVar(&readyTimeout, "ready-timeout", 30*time.Second, "Max duration to wait for the cmd to become ready")
+	flag.StringVar(&onReadyCmd, "on-ready", "", "Shell command to run after the cmd becomes ready")
+	flag.StringVar(&restartPolicy, "restart-on-exit", restartNever, "Restart the cmd when it exits on its own, one of never, on-failure or always")
+	flag.IntVar(&maxRestarts, "max-restarts", 5, "Max number of restarts within the restart window before giving up")
+	flag.DurationVar(&restartWindow, "restart-window", time.Minute, "Window in which restarts are counted towards the max restarts")
+	flag.DurationVar(&runTimeout, "timeout", 0, "Max duration of each run of the cmd, or of each file when using each, before it is killed, no timeout if 0")
+	flag.BoolVar(&once, "once", false, "Wait for the first change, run the cmd, then exit with its exit code")
+	flag.BoolVar(&noInitialRun, "no-initial-run", false, "Skip running the cmd on startup")
+	flag.IntVar(&exitAfter, "exit-after", 0, "Exit with the exit code of the cmd after it has run this many times, never exits if 0")
+	flag.StringVar(&stateDir, "state-dir", "", "Directory the run history is stored in, defaults to a per project directory in the user cache directory")
+	flag.StringVar(&logDir, "log-dir", "", "Directory to write the output of each run to, as a timestamped file alongside a latest.log link")
+	flag.IntVar(&logMaxFiles, "log-max-files", 20, "Max number of run log files retained in the log dir, unlimited if 0")
+	flag.Int64Var(&logMaxBytes, "log-max-bytes", 0, "Max total size in bytes of the run log files retained in the log dir, unlimited if 0")
+	flag.BoolVar(&logStripANSI, "log-strip-ansi", false, "Remove ANSI escape sequences from the run log files")
+	flag.BoolVar(&noHistory, "no-history", false, "Disable recording the run history")
+	flag.IntVar(&historyMaxRuns, "history-max-runs", history.DefaultMaxRecords, "Max number of runs retained in the run history, unlimited if 0")
+	flag.StringVar(&preRunCmd, "pre-run", "", "Shell command to run before each execution of the cmd")
+	flag.StringVar(&postRunCmd, "post-run", "", "Shell command to run after each execution of the cmd exits")
+	flag.StringVar(&onSuccessCmd, "on-success", "", "Shell command to run after the cmd exits successfully")
+	flag.StringVar(&onFailureCmd, "on-failure", "", "Shell command to run after the cmd exits with a non-zero exit code")
+	flag.StringVar(&onFirstFailureCmd, "on-first-failure", "", "Shell command to run after the cmd fails following a success")
+	flag.StringVar(&onRecoveryCmd, "on-recovery", "", "Shell command to run after the cmd succeeds following a failure")
+	flag.StringVar(&eventsStr, "events", "added,changed,removed", "Comma separated event types the cmd reacts to")
 
 	flag.Parse()
 
-	if cmd == "" {
+	// write the cmd output alongside that of the hooks
+	setupCmdWriter()
+
+	// run go test in place of the cmd
+	if goTest {
+		if cmd != "" || each {
+			os.Stderr.WriteString("The `--go-test` argument cannot be used with `--cmd` or `--each`\n")
+			os.Exit(2)
+		}
+		setupGoTest()
+	}
+
+	if cmd == "" && onAddedCmd == "" && onChangedCmd == "" && onRemovedCmd == "" {
 		os.Stderr.WriteString("No `--cmd` argument provided, Set command to execute with `--cmd=\"<shell command>\"`\n")
 		os.Exit(1)
 	}
@@ -187,6 +492,49 @@ func main() {
 	}
 	watch = splitAndTrim(watchStr)
 
+	// run once then exit
+	if once {
+		noInitialRun = true
+		exitAfter = 1
+	}
+
+	// validate the restart policy
+	err := validateRestartPolicy(restartPolicy)
+	if err != nil {
+		os.Stderr.WriteString(fmt.Sprintf("Invalid `--restart-on-exit` argument: %s\n", err))
+		os.Exit(2)
+	}
+
+	// parse the event types the cmd reacts to
+	eventTypes, err = parseEventTypes(eventsStr)
+	if err != nil {
+		os.Stderr.WriteString(fmt.Sprintf("Invalid `--events` argument: %s\n", err))
+		os.Exit(2)
+	}
+
+	// parse the log format
+	format, ok := writer.ParseFormat(logFormatStr)
+	if !ok {
+		os.Stderr.WriteString(fmt.Sprintf("Invalid `--log-format` argument: %s\n", logFormatStr))
+		os.Exit(2)
+	}
+	setLogFormat(format)
+
+	// parse the output filtering and highlighting rules
+	outputRules, err = parseOutputRules()
+	if err != nil {
+		os.Stderr.WriteString(fmt.Sprintf("Invalid %s\n", err))
+		os.Exit(2)
+	}
+
+	// parse the problem matchers
+	matchers, err := parseMatchers(problemsStr, problemPatterns)
+	if err != nil {
+		os.Stderr.WriteString(fmt.Sprintf("Invalid `--problems` argument: %s\n", err))
+		os.Exit(2)
+	}
+	setupProblems(matchers)
+
 	// ignores are optional
 	if ignoreStr != "" {
 		ignore = splitAndTrim(ignoreStr)
@@ -194,16 +542,30 @@ func main() {
 
 	// set token size
 	cmdWriter.MaxTokenSize(maxTokenSize)
+	cmdWriter.AddSink(outputTail)
+
+	// only use a pseudo terminal if the output is going to a terminal
+	usePty = !noPty && isTerminal(os.Stdout)
+	cmdWriter.TintStderr(tintStderr)
+	cmdWriter.PassThrough(passThrough)
+	if outputRules != nil {
+		cmdWriter.Rules(outputRules)
+	}
 
 	// print logo
-	fmt.Fprintf(os.Stdout, createLogo())
+	if logFormat == writer.TextFormat {
+		fmt.Fprintf(os.Stdout, createLogo())
+	}
 
 	// create the watcher
 	w := watcher.New()
 
 	// add watches
 	for _, arg := range watch {
-		prettyWriter.WriteStringf("watching %s\n", color.BlueString(arg))
+		prettyWriter.WriteEntryf(writer.Entry{
+			Type: "watch_start",
+			Path: arg,
+		}, "watching %s\n", color.BlueString(arg))
 		w.Watch(arg)
 	}
 
@@ -213,6 +575,26 @@ func main() {
 		w.Ignore(arg)
 	}
 
+	// the control socket is only served when requested
+	if !serveCtl {
+		socketPath = ""
+	}
+
+	// never watch the control socket
+	if socketPath != "" {
+		w.Ignore(socketPath)
+	}
+
+	// never watch the run logs
+	if logDir != "" {
+		w.Ignore(logDir)
+	}
+
+	// never watch the errorfile
+	if errorfile != "" {
+		w.Ignore(errorfile)
+	}
+
 	// check for initial target count
 	numTargets, err := w.NumTargets()
 	if err != nil {
@@ -220,23 +602,134 @@ func main() {
 		os.Exit(3)
 	}
 	prettyWriter.WriteStringf("%s\n", fileCountString(numTargets))
+	onTargetCount(numTargets)
+
+	// fingerprint the watched files to skip redundant runs
+	if skipUnchanged {
+		err = setupInputs(w)
+		if err != nil {
+			prettyWriter.WriteErrorf("failed to fingerprint watched files: %s\n", err)
+		}
+	}
+
+	// serve the status and controls
+	if httpAddr != "" || socketPath != "" {
+		startServer(w)
+	}
+	if httpAddr != "" {
+		err := apiServer.Listen(httpAddr)
+		if err != nil {
+			os.Stderr.WriteString(fmt.Sprintf("Failed to start http server: %s\n", err))
+			os.Exit(3)
+		}
+		prettyWriter.WriteStringf("serving http on %s\n", color.BlueString(httpAddr))
+	}
+	if socketPath != "" {
+		err := apiServer.Listen("unix:" + socketPath)
+		if err != nil {
+			prettyWriter.WriteErrorf("failed to serve control socket: %s\n", err)
+		}
+	}
+
+	// record the run history
+	if !noHistory {
+		runHistory, err = openHistory(stateDir)
+		if err != nil {
+			prettyWriter.WriteErrorf("failed to open history: %s\n", err)
+		} else {
+			runHistory.MaxRecords(historyMaxRuns)
+		}
+	}
+
+	// write the output of each run to a log file
+	if logDir != "" {
+		err = openRunLogs()
+		if err != nil {
+			prettyWriter.WriteErrorf("failed to open log dir: %s\n", err)
+		}
+	}
+
+	// probe whether the cmd is ready
+	err = setupProbes()
+	if err != nil {
+		os.Stderr.WriteString(fmt.Sprintf("Invalid `--ready-pattern` argument: %s\n", err))
+		os.Exit(2)
+	}
+
+	// proxy requests to the cmd
+	if proxyAddr != "" {
+		if proxyTarget == "" {
+			os.Stderr.WriteString("No `--proxy-target` argument provided. Set the address the cmd listens on with `--proxy-target=\"<host>:<port>\"`\n")
+			os.Exit(2)
+		}
+		err := startProxy(proxyAddr, proxyTarget)
+		if err != nil {
+			os.Stderr.WriteString(fmt.Sprintf("Failed to start proxy: %s\n", err))
+			os.Exit(3)
+		}
+		prettyWriter.WriteStringf("proxying %s to %s\n", color.BlueString(proxyAddr), color.BlueString(proxyTarget))
+	}
+
+	// serve live reload to browsers
+	if liveReloadAddr != "" {
+		err := startLiveReload(liveReloadAddr)
+		if err != nil {
+			os.Stderr.WriteString(fmt.Sprintf("Failed to start live reload server: %s\n", err))
+			os.Exit(3)
+		}
+		prettyWriter.WriteStringf("serving live reload on %s\n", color.BlueString("http://%s/livereload.js", liveReloadAddr))
+	}
+
+	// propagate terminal size changes to the cmd
+	if usePty {
+		forwardWindowSize()
+	}
+
+	// forward terminal input to the cmd
+	if interactive {
+		if !usePty || !isTerminal(os.Stdin) {
+			prettyWriter.WriteStringf("input forwarding requires a terminal and pseudo terminal, ignoring %s\n", color.BlueString("--interactive"))
+		} else {
+			err := forwardStdin()
+			if err != nil {
+				prettyWriter.WriteErrorf("failed to forward input: %s\n", err)
+			} else {
+				prettyWriter.WriteStringf("press %s to quit\n", color.MagentaString("Ctrl-]"))
+			}
+		}
+	}
+
+	// read keyboard controls
+	if !interactive && !noKeys && isTerminal(os.Stdin) {
+		err := readKeys()
+		if err != nil {
+			prettyWriter.WriteErrorf("failed to read keys: %s\n", err)
+		} else {
+			prettyWriter.WriteStringf("press %s for help\n", color.MagentaString("?"))
+		}
+	}
 
 	// gracefully shutdown cmd process on exit
 	graceful.OnSignal(func() {
-		// kill process
 		prettyWriter.WriteStringf("\r") // hide the ^C
-		killCmd()
-		spin.Done()
-		os.Exit(0)
+		shutdown(0)
 	})
 
 	// flag that we are ready to launch process
 	ready <- true
 
-	// launch cmd process
-	err = executeCmd(cmd)
-	if err != nil {
-		prettyWriter.WriteStringf("failed to run cmd: %s\n", err)
+	if cmd == "" {
+		// only event type specific cmds are run
+		prettyWriter.WriteStringf("waiting for changes\n")
+	} else if each || noInitialRun {
+		// only run against changes
+		prettyWriter.WriteStringf("waiting for changes to run %s\n", color.MagentaString(cmd))
+	} else {
+		// launch cmd process
+		err = executeCmd(cmd, nil)
+		if err != nil {
+			prettyWriter.WriteErrorf("failed to run cmd: %s\n", err)
+		}
 	}
 
 	// track which action to take
@@ -245,18 +738,24 @@ func main() {
 
 	// start scan loop
 	for {
-		if nextWatch == watchInterval {
+		if nextWatch == watchInterval && !paused {
 			// prev number targets
 			prevTargets := numTargets
 
 			// check if anything has changed
 			events, err := w.ScanForEvents()
 			if err != nil {
-				prettyWriter.WriteStringf("failed to run scan: %s\n", err)
+				prettyWriter.WriteErrorf("failed to run scan: %s\n", err)
 			}
 			// log changes
 			for _, event := range events {
-				prettyWriter.WriteStringf("%s\n", fileChangeString(event.Path, event.Type))
+				prettyWriter.WriteEntryf(writer.Entry{
+					Type:  "file_event",
+					Path:  event.Path,
+					Event: event.Type.String(),
+				}, "%s\n", fileChangeString(event.Path, event.Type))
+				onFileEvent(event)
+				updateInputs(event)
 				// update num targets
 				if event.Type == watcher.Added {
 					numTargets++
@@ -269,13 +768,40 @@ func main() {
 			// log new target count
 			if prevTargets != numTargets {
 				prettyWriter.WriteStringf("%s\n", fileCountString(numTargets))
+				onTargetCount(numTargets)
+			}
+
+			// run any event type specific commands
+			triggered, remaining := splitTriggered(events)
+			if len(triggered) > 0 {
+				go executeTriggers(triggered)
 			}
 
+			// only react to the requested event types
+			remaining = filterEvents(remaining, eventTypes)
+
 			// if so, execute command
-			if len(events) > 0 {
-				err := executeCmd(cmd)
-				if err != nil {
-					prettyWriter.WriteStringf("failed to run cmd: %s\n", err)
+			if len(remaining) > 0 && cmd != "" {
+				if each {
+					go executeEach(remaining)
+				} else {
+					command, affected := cmd, true
+					if goTest {
+						// only test the packages affected by the changes
+						command, affected = affectedCmd(remaining)
+					}
+					switch {
+					case !affected:
+						prettyWriter.WriteStringf("no packages affected by the changes\n")
+					case inputsUnchanged(command):
+						prettyWriter.WriteStringf("inputs unchanged, skipping\n")
+					default:
+						cancelRestart(true)
+						err := executeCmd(command, remaining)
+						if err != nil {
+							prettyWriter.WriteErrorf("failed to run cmd: %s\n", err)
+						}
+					}
 				}
 			}
 		}
@@ -315,8 +841,14 @@ func main() {
 			sleep = watchInterval
 		}
 
-		// sleep
-		time.Sleep(time.Millisecond * time.Duration(sleep))
+		// sleep until the next iter, or until an action is requested
+		select {
+		case a := <-actions:
+			handleAction(a, w)
+		case code := <-exits:
+			shutdown(code)
+		case <-time.After(time.Millisecond * time.Duration(sleep)):
+		}
 	}
 
 }
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/fatih/color"
 
+	"github.com/kbirk/witch/fingerprint"
 	"github.com/kbirk/witch/watcher"
 )
 
@@ -88,6 +94,373 @@ func TestFileChangeString(t *testing.T) {
 	}
 }
 
+func TestSubstitutePath(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		path string
+		want string
+	}{
+		{
+			name: "placeholder",
+			cmd:  "optipng {} -o out/{}",
+			path: "img/a.png",
+			want: "optipng 'img/a.png' -o out/'img/a.png'",
+		},
+		{
+			name: "no placeholder appends path",
+			cmd:  "golint",
+			path: "main.go",
+			want: "golint 'main.go'",
+		},
+		{
+			name: "quotes are escaped",
+			cmd:  "cat {}",
+			path: "it's.txt",
+			want: `cat 'it'\''s.txt'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := substitutePath(tt.cmd, tt.path)
+			if got != tt.want {
+				t.Fatalf("substitutePath(%q, %q) = %q, want %q", tt.cmd, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEventTypes(t *testing.T) {
+	got, err := parseEventTypes("added, removed")
+	if err != nil {
+		t.Fatalf("parseEventTypes failed: %v", err)
+	}
+	want := map[watcher.EventType]bool{
+		watcher.Added:   true,
+		watcher.Removed: true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseEventTypes() = %#v, want %#v", got, want)
+	}
+
+	if _, err := parseEventTypes("added,renamed"); err == nil {
+		t.Fatal("parseEventTypes() with unrecognized type succeeded, want error")
+	}
+}
+
+func TestSplitTriggered(t *testing.T) {
+	oldOnAdded := onAddedCmd
+	onAddedCmd = "make migrate"
+	t.Cleanup(func() {
+		onAddedCmd = oldOnAdded
+	})
+
+	events := []watcher.Event{
+		{Type: watcher.Added, Path: "migrations/002.sql"},
+		{Type: watcher.Changed, Path: "main.go"},
+		{Type: watcher.Removed, Path: "old.go"},
+	}
+	triggered, remaining := splitTriggered(events)
+
+	wantTriggered := map[watcher.EventType][]watcher.Event{
+		watcher.Added: {{Type: watcher.Added, Path: "migrations/002.sql"}},
+	}
+	if !reflect.DeepEqual(triggered, wantTriggered) {
+		t.Fatalf("triggered = %#v, want %#v", triggered, wantTriggered)
+	}
+	wantRemaining := []watcher.Event{
+		{Type: watcher.Changed, Path: "main.go"},
+		{Type: watcher.Removed, Path: "old.go"},
+	}
+	if !reflect.DeepEqual(remaining, wantRemaining) {
+		t.Fatalf("remaining = %#v, want %#v", remaining, wantRemaining)
+	}
+}
+
+func TestPostRunEnv(t *testing.T) {
+	r := &run{
+		events: []watcher.Event{
+			{Type: watcher.Changed, Path: "main.go"},
+			{Type: watcher.Added, Path: "api/api.go"},
+		},
+		exitCode: 2,
+		duration: 1500 * time.Millisecond,
+	}
+	got := postRunEnv(r)
+	want := []string{
+		"WITCH_FILES=main.go\napi/api.go",
+		"WITCH_OUTCOME=failure",
+		"WITCH_EXIT_CODE=2",
+		"WITCH_DURATION=1.5s",
+		"WITCH_DURATION_MS=1500",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("postRunEnv() = %#v, want %#v", got, want)
+	}
+}
+
+func TestPostRunHooks(t *testing.T) {
+	killed := &run{exit: runExit{killed: true}}
+	timedOut := &run{exitCode: -1, exit: runExit{timedOut: true}}
+
+	tests := []struct {
+		name string
+		runs []*run
+		want [][]string
+	}{
+		{
+			name: "first runs have no transition",
+			runs: []*run{{exitCode: 0}, {exitCode: 0}},
+			want: [][]string{
+				{"post-run", "on-success"},
+				{"post-run", "on-success"},
+			},
+		},
+		{
+			name: "first failure and recovery",
+			runs: []*run{{exitCode: 0}, {exitCode: 2}, {exitCode: 1}, {exitCode: 0}},
+			want: [][]string{
+				{"post-run", "on-success"},
+				{"post-run", "on-failure", "on-first-failure"},
+				{"post-run", "on-failure"},
+				{"post-run", "on-success", "on-recovery"},
+			},
+		},
+		{
+			name: "killed runs neither succeed nor fail",
+			runs: []*run{{exitCode: 1}, killed, {exitCode: 0}},
+			want: [][]string{
+				{"post-run", "on-failure"},
+				{"post-run"},
+				{"post-run", "on-success", "on-recovery"},
+			},
+		},
+		{
+			name: "timeouts fail",
+			runs: []*run{{exitCode: 0}, timedOut},
+			want: [][]string{
+				{"post-run", "on-success"},
+				{"post-run", "on-failure", "on-first-failure"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prevSucceeded = nil
+			t.Cleanup(func() {
+				prevSucceeded = nil
+			})
+			for i, r := range tt.runs {
+				got := postRunHooks(r)
+				if !reflect.DeepEqual(got, tt.want[i]) {
+					t.Fatalf("run %d: postRunHooks() = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestKeyAction(t *testing.T) {
+	tests := []struct {
+		key    byte
+		action action
+		ok     bool
+	}{
+		{key: 'r', action: rerunAction, ok: true},
+		{key: 'p', action: togglePauseAction, ok: true},
+		{key: 'q', action: quitAction, ok: true},
+		{key: 'x', ok: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := keyAction(tt.key)
+		if ok != tt.ok || (ok && got != tt.action) {
+			t.Fatalf("keyAction(%q) = %d, %t, want %d, %t", tt.key, got, ok, tt.action, tt.ok)
+		}
+	}
+}
+
+func TestFindSocket(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("failed to create nested directory: %v", err)
+	}
+
+	if _, err := findSocket(nested); err == nil {
+		t.Fatal("findSocket() without a socket succeeded, want error")
+	}
+
+	socket := filepath.Join(root, defaultSocket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", socket, err)
+	}
+	defer listener.Close()
+
+	got, err := findSocket(nested)
+	if err != nil {
+		t.Fatalf("findSocket() failed: %v", err)
+	}
+	if got != socket {
+		t.Fatalf("findSocket() = %q, want %q", got, socket)
+	}
+}
+
+func TestRestartDelay(t *testing.T) {
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{n: 0, want: 500 * time.Millisecond},
+		{n: 1, want: time.Second},
+		{n: 3, want: 4 * time.Second},
+		{n: 10, want: 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		got := restartDelay(tt.n)
+		if got != tt.want {
+			t.Fatalf("restartDelay(%d) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	tests := []struct {
+		policy   string
+		exitCode int
+		want     bool
+	}{
+		{policy: restartNever, exitCode: 1, want: false},
+		{policy: restartOnFailure, exitCode: 0, want: false},
+		{policy: restartOnFailure, exitCode: 1, want: true},
+		{policy: restartAlways, exitCode: 0, want: true},
+	}
+
+	for _, tt := range tests {
+		got := shouldRestart(tt.policy, tt.exitCode)
+		if got != tt.want {
+			t.Fatalf("shouldRestart(%q, %d) = %t, want %t", tt.policy, tt.exitCode, got, tt.want)
+		}
+	}
+}
+
+func TestRecentRestarts(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	times := []time.Time{
+		now.Add(-2 * time.Minute),
+		now.Add(-30 * time.Second),
+		now.Add(-time.Second),
+	}
+	got := recentRestarts(times, now, time.Minute)
+	want := times[1:]
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("recentRestarts() = %v, want %v", got, want)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		r    *run
+		want int
+	}{
+		{name: "success", r: &run{exitCode: 0}, want: 0},
+		{name: "failure", r: &run{exitCode: 2}, want: 2},
+		{name: "signal", r: &run{exitCode: -1, signal: syscall.SIGTERM}, want: 143},
+		{name: "killed", r: &run{exitCode: -1, signal: syscall.SIGKILL}, want: 137},
+		{name: "unknown", r: &run{exitCode: -1}, want: 1},
+		{name: "timeout", r: &run{exitCode: -1, exit: runExit{timedOut: true}}, want: timeoutExitCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := exitStatus(tt.r)
+			if got != tt.want {
+				t.Fatalf("exitStatus() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHighlight(t *testing.T) {
+	tests := []struct {
+		arg     string
+		pattern string
+		attr    color.Attribute
+	}{
+		{arg: "FAIL", pattern: "FAIL", attr: color.FgRed},
+		{arg: "yellow:warn(ing)?", pattern: "warn(ing)?", attr: color.FgYellow},
+		{arg: "panic:", pattern: "panic:", attr: color.FgRed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			re, attr, err := parseHighlight(tt.arg)
+			if err != nil {
+				t.Fatalf("parseHighlight() error = %v", err)
+			}
+			if re.String() != tt.pattern || attr != tt.attr {
+				t.Fatalf("parseHighlight() = %q, %v, want %q, %v", re.String(), attr, tt.pattern, tt.attr)
+			}
+		})
+	}
+}
+
+func TestParseMatchers(t *testing.T) {
+	matchers, err := parseMatchers("go, gotest", []string{`^(?P<file>\S+):(?P<line>\d+)$`})
+	if err != nil {
+		t.Fatalf("parseMatchers() error = %v", err)
+	}
+	if len(matchers) != 3 || matchers[0].Name != "go" || matchers[2].Name != "custom" {
+		t.Fatalf("parseMatchers() = %v, want go, gotest and custom matchers", matchers)
+	}
+
+	if _, err := parseMatchers("rust", nil); err == nil {
+		t.Fatal("expected an error for an unrecognized matcher")
+	}
+}
+
+func TestInputsUnchanged(t *testing.T) {
+	inputs = fingerprint.New()
+	t.Cleanup(func() {
+		inputs = nil
+		successfulInputs = make(map[string]string)
+	})
+
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	updateInputs(watcher.Event{Type: watcher.Added, Path: path})
+
+	if inputsUnchanged("make") {
+		t.Fatal("inputs unchanged before any successful run")
+	}
+	recordInputs(&run{command: "make", inputs: currentInputs(), exitCode: 1})
+	if inputsUnchanged("make") {
+		t.Fatal("inputs unchanged after a failed run")
+	}
+	recordInputs(&run{command: "make", inputs: currentInputs()})
+	if !inputsUnchanged("make") {
+		t.Fatal("inputs changed after a successful run")
+	}
+	if inputsUnchanged("make test") {
+		t.Fatal("inputs unchanged for a different cmd")
+	}
+
+	if err := os.WriteFile(path, []byte("package main // edited\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	updateInputs(watcher.Event{Type: watcher.Changed, Path: path})
+	if inputsUnchanged("make") {
+		t.Fatal("inputs unchanged after the contents changed")
+	}
+}
+
 func withNoColor(t *testing.T) {
 	t.Helper()
 	oldNoColor := color.NoColor
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/kbirk/witch/gotest"
+	"github.com/kbirk/witch/problem"
+	"github.com/kbirk/witch/server"
+	"github.com/kbirk/witch/writer"
+)
+
+const (
+	maxListedProblems = 20
+)
+
+var (
+	problemsStr     string
+	problemPatterns patternsFlag
+	errorfile       string
+	problems        *problem.Collector
+)
+
+func parseMatchers(names string, patterns []string) ([]problem.Matcher, error) {
+	var matchers []problem.Matcher
+	for _, name := range splitAndTrim(names) {
+		m, ok := problem.Builtin(name)
+		if !ok {
+			return nil, fmt.Errorf("unrecognized matcher `%s`, expected one of %s", name, strings.Join(problem.Builtins(), ", "))
+		}
+		matchers = append(matchers, m)
+	}
+	for _, pattern := range patterns {
+		m, err := problem.NewMatcher("custom", pattern)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func setupProblems(matchers []problem.Matcher) {
+	if len(matchers) == 0 {
+		return
+	}
+	problems = problem.NewCollector(matchers...)
+	problems.ResolvePackages(func(pkg string) (string, bool) {
+		return gotest.PackageDir(".", pkg)
+	})
+	cmdWriter.AddSink(problems)
+}
+
+func resetProblems() {
+	if problems != nil {
+		problems.Reset()
+	}
+}
+
+func problemString(p problem.Problem) string {
+	location := fmt.Sprintf("%s:%d", p.File, p.Line)
+	if p.Col > 0 {
+		location = fmt.Sprintf("%s:%d", location, p.Col)
+	}
+	severity := color.RedString(p.Severity)
+	if p.Severity != "error" && p.Severity != "fatal error" {
+		severity = color.YellowString(p.Severity)
+	}
+	return fmt.Sprintf("  %s %s %s", color.BlueString(location), severity, p.Message)
+}
+
+func problemsCountString(count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%s problem", color.RedString("1"))
+	}
+	return fmt.Sprintf("%s problems", color.RedString("%d", count))
+}
+
+// reportProblems summarizes the problems found in the output of the run.
+func reportProblems(r *run) {
+	if problems == nil {
+		return
+	}
+	found := problems.Problems()
+
+	updateStatus(func(s *server.Status) {
+		s.LastProblems = found
+	})
+
+	if errorfile != "" {
+		err := problem.WriteErrorfile(errorfile, found)
+		if err != nil {
+			prettyWriter.WriteErrorf("failed to write errorfile: %s\n", err)
+		}
+	}
+
+	if r.exit.killed || len(found) == 0 {
+		return
+	}
+
+	for _, p := range found {
+		prettyWriter.WriteEntry(writer.Entry{
+			Type:       "problem",
+			Path:       p.File,
+			LineNumber: p.Line,
+			Col:        p.Col,
+			Severity:   p.Severity,
+			Message:    p.Message,
+		})
+	}
+
+	if logFormat == writer.JSONFormat {
+		return
+	}
+	listed := found
+	if len(listed) > maxListedProblems {
+		listed = listed[:maxListedProblems]
+	}
+	lines := make([]string, 0, len(listed))
+	for _, p := range listed {
+		lines = append(lines, problemString(p))
+	}
+	if len(found) > len(listed) {
+		lines = append(lines, color.HiBlackString("  and %d more", len(found)-len(listed)))
+	}
+	prettyWriter.WriteStringf("found %s\n", problemsCountString(len(found)))
+	console.WriteLine(strings.Join(lines, "\n") + "\n")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/kbirk/witch/probe"
+	"github.com/kbirk/witch/server"
+)
+
+const (
+	readyInterval = 100 * time.Millisecond
+	startingState = "starting"
+	readyState    = "ready"
+	failedState   = "failed"
+)
+
+var (
+	readyTCP     string
+	readyHTTP    string
+	readyPattern string
+	readyTimeout time.Duration
+	onReadyCmd   string
+	probes       []probe.Probe
+	patternProbe *probe.Pattern
+)
+
+func setupProbes() error {
+	if readyTCP != "" {
+		probes = append(probes, probe.TCP(readyTCP))
+	}
+	if readyHTTP != "" {
+		probes = append(probes, probe.HTTP(readyHTTP))
+	}
+	if readyPattern != "" {
+		p, err := probe.NewPattern(readyPattern)
+		if err != nil {
+			return err
+		}
+		patternProbe = p
+		cmdWriter.AddSink(p)
+		probes = append(probes, p)
+	}
+	return nil
+}
+
+func readyStateString(state string) string {
+	switch state {
+	case startingState:
+		return color.YellowString(state)
+	case readyState:
+		return color.GreenString(state)
+	case failedState:
+		return color.RedString(state)
+	}
+	return state
+}
+
+func setReadyState(state string) {
+	updateStatus(func(s *server.Status) {
+		s.State = state
+	})
+	spin.Label(readyStateString(state))
+	broadcast(server.Event{
+		Type:  "state",
+		Event: state,
+	})
+}
+
+// exitReadyState resets the ready state once the cmd exits, as it is no
+// longer ready.
+func exitReadyState(r *run) {
+	if len(probes) == 0 {
+		return
+	}
+	if !r.exit.killed && (r.exit.timedOut || r.exitCode != 0) {
+		setReadyState(failedState)
+		return
+	}
+	setReadyState("")
+}
+
+func resetProbes() {
+	if patternProbe != nil {
+		patternProbe.Reset()
+	}
+}
+
+// awaitReady probes the cmd until it is ready, the timeout elapses, or the
+// cmd exits.
+func awaitReady(r *run) {
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	r.stopProbes = cancel
+	setReadyState(startingState)
+
+	go func() {
+		defer cancel()
+		err := probe.Wait(ctx, probes, readyInterval)
+		if err != nil {
+			mu.Lock()
+			killed := r.killed
+			mu.Unlock()
+			if killed {
+				// a new run has already started
+				return
+			}
+			if errors.Is(ctx.Err(), context.Canceled) {
+				prettyWriter.WriteStringf("cmd exited before it was %s\n", readyStateString(readyState))
+			} else {
+				prettyWriter.WriteStringf("cmd failed to become ready: %s\n", err)
+			}
+			setReadyState(failedState)
+			if devProxy != nil {
+				devProxy.Fail(outputTail.String())
+			}
+			return
+		}
+		prettyWriter.WriteStringf("cmd %s after %s\n",
+			readyStateString(readyState),
+			color.BlueString(time.Since(r.start).Round(time.Millisecond).String()))
+		setReadyState(readyState)
+		if devProxy != nil {
+			devProxy.Ready()
+		}
+		runHook("on-ready", onReadyCmd, preRunEnv(r))
+		reloadBrowsers(r.events)
+	}()
+}
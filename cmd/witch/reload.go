@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/fatih/color"
+
+	"github.com/kbirk/witch/livereload"
+	"github.com/kbirk/witch/watcher"
+)
+
+var (
+	liveReloadAddr string
+	liveReload     *livereload.Server
+)
+
+func startLiveReload(addr string) error {
+	s := livereload.New()
+	err := s.Listen(addr)
+	if err != nil {
+		return err
+	}
+	liveReload = s
+	return nil
+}
+
+func stopLiveReload() {
+	if liveReload != nil {
+		liveReload.Close()
+	}
+}
+
+// reloadBrowsers notifies any connected browsers to reload, only swapping
+// stylesheets if the changes were limited to them.
+func reloadBrowsers(events []watcher.Event) {
+	if liveReload == nil {
+		return
+	}
+	if livereload.IsCSSOnly(eventPaths(events)) {
+		if liveReload.NumClients() > 0 {
+			prettyWriter.WriteStringf("reloading %s\n", color.BlueString("css"))
+		}
+		liveReload.ReloadCSS()
+		return
+	}
+	if liveReload.NumClients() > 0 {
+		prettyWriter.WriteStringf("reloading %s\n", color.BlueString("browser"))
+	}
+	liveReload.Reload()
+}
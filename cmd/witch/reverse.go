@@ -0,0 +1,52 @@
+package main
+
+import (
+	"time"
+
+	"github.com/kbirk/witch/proxy"
+)
+
+const (
+	proxyTimeout = 30 * time.Second
+)
+
+var (
+	proxyAddr   string
+	proxyTarget string
+	devProxy    *proxy.Proxy
+)
+
+func startProxy(addr string, target string) error {
+	p, err := proxy.New(target, proxyTimeout)
+	if err != nil {
+		return err
+	}
+	// only forward requests once the cmd is ready
+	if len(probes) > 0 {
+		p.RequireReady()
+	}
+	err = p.Listen(addr)
+	if err != nil {
+		return err
+	}
+	devProxy = p
+	return nil
+}
+
+func stopProxy() {
+	if devProxy != nil {
+		devProxy.Close()
+	}
+}
+
+func proxyRestart() {
+	if devProxy != nil {
+		devProxy.Restart()
+	}
+}
+
+func proxyExit(r *run) {
+	if devProxy != nil && !r.exit.killed && r.exitCode != 0 {
+		devProxy.Fail(outputTail.String())
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kbirk/witch/writer"
+)
+
+var (
+	clearScreen bool
+	noSeparator bool
+	numRuns     int
+)
+
+// beginRun clears the screen and separates the output of each run. Runs are
+// serialized, so it is only called by one run at a time.
+func beginRun() {
+	numRuns++
+	if clearScreen && logFormat == writer.TextFormat && isTerminal(os.Stdout) {
+		console.Clear()
+	}
+	if !noSeparator {
+		prettyWriter.WriteSeparator(fmt.Sprintf("run %d", numRuns))
+	}
+}
+
+// endRun summarizes the run once it exits.
+func endRun(r *run) {
+	if noSeparator {
+		return
+	}
+	prettyWriter.WriteSummary(writer.RunSummary{
+		Outcome:  r.outcome(),
+		ExitCode: r.exitCode,
+		Duration: r.duration,
+		Files:    eventPaths(r.events),
+	})
+}
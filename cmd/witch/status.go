@@ -0,0 +1,152 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/kbirk/witch/server"
+	"github.com/kbirk/witch/watcher"
+)
+
+var (
+	httpAddr   string
+	socketPath string
+	serveCtl   bool
+	apiServer  *server.Server
+	statusMu   = &sync.Mutex{}
+	status     server.Status
+)
+
+// controller exposes the watch to the server.
+type controller struct {
+	w *watcher.Watcher
+}
+
+func (c *controller) Status() server.Status {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	return status
+}
+
+func (c *controller) Run() {
+	actions <- rerunAction
+}
+
+func (c *controller) Pause() {
+	actions <- pauseAction
+}
+
+func (c *controller) Resume() {
+	actions <- resumeAction
+}
+
+func (c *controller) Files() ([]string, error) {
+	targets, err := c.w.Targets()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
+
+func (c *controller) Stop() {
+	actions <- quitAction
+}
+
+// outputSink broadcasts each line of cmd output.
+type outputSink struct{}
+
+func (s outputSink) Write(p []byte) (int, error) {
+	broadcast(server.Event{
+		Type: "output",
+		Line: string(p),
+	})
+	return len(p), nil
+}
+
+func startServer(w *watcher.Watcher) {
+	apiServer = server.New(&controller{
+		w: w,
+	})
+	cmdWriter.AddSink(outputSink{})
+}
+
+func stopServer() {
+	if apiServer != nil {
+		apiServer.Close()
+	}
+}
+
+func updateStatus(fn func(s *server.Status)) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	fn(&status)
+}
+
+func broadcast(event server.Event) {
+	if apiServer != nil {
+		apiServer.Broadcast(event)
+	}
+}
+
+func onFileEvent(event watcher.Event) {
+	broadcast(server.Event{
+		Type:  "file",
+		Path:  event.Path,
+		Event: event.Type.String(),
+	})
+}
+
+func onTargetCount(count uint64) {
+	updateStatus(func(s *server.Status) {
+		s.WatchedFiles = count
+	})
+}
+
+func onPause(paused bool) {
+	updateStatus(func(s *server.Status) {
+		s.Paused = paused
+	})
+	typ := "resume"
+	if paused {
+		typ = "pause"
+	}
+	broadcast(server.Event{
+		Type: typ,
+	})
+}
+
+func onRunStart(r *run) {
+	pid := r.cmd.Process.Pid
+	updateStatus(func(s *server.Status) {
+		s.Running = true
+		s.Cmd = r.command
+		s.Pid = pid
+		s.StartedAt = &r.start
+	})
+	broadcast(server.Event{
+		Type:  "run_start",
+		Time:  r.start,
+		Cmd:   r.command,
+		Pid:   pid,
+		Files: eventPaths(r.events),
+	})
+}
+
+func onRunExit(r *run) {
+	exitCode := r.exitCode
+	updateStatus(func(s *server.Status) {
+		s.Running = false
+		s.Pid = 0
+		s.LastExitCode = &exitCode
+		s.LastOutcome = r.outcome()
+		s.LastDurationMs = r.duration.Milliseconds()
+	})
+	broadcast(server.Event{
+		Type:       "run_exit",
+		Cmd:        r.command,
+		ExitCode:   &exitCode,
+		Outcome:    r.outcome(),
+		DurationMs: r.duration.Milliseconds(),
+	})
+}
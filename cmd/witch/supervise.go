@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+const (
+	restartNever     = "never"
+	restartOnFailure = "on-failure"
+	restartAlways    = "always"
+	restartBaseDelay = 500 * time.Millisecond
+	restartMaxDelay  = 30 * time.Second
+)
+
+var (
+	restartPolicy string
+	maxRestarts   int
+	restartWindow time.Duration
+	restartMu     = &sync.Mutex{}
+	restartTimer  *time.Timer
+	restarts      []time.Time
+)
+
+func validateRestartPolicy(policy string) error {
+	switch policy {
+	case restartNever, restartOnFailure, restartAlways:
+		return nil
+	}
+	return fmt.Errorf("unrecognized restart policy `%s`", policy)
+}
+
+func shouldRestart(policy string, exitCode int) bool {
+	switch policy {
+	case restartAlways:
+		return true
+	case restartOnFailure:
+		return exitCode != 0
+	}
+	return false
+}
+
+// restartDelay returns the exponential backoff delay given the number of
+// recent restarts.
+func restartDelay(n int) time.Duration {
+	delay := restartBaseDelay
+	for i := 0; i < n; i++ {
+		delay *= 2
+		if delay >= restartMaxDelay {
+			return restartMaxDelay
+		}
+	}
+	return delay
+}
+
+// recentRestarts drops any restarts that occurred outside of the window.
+func recentRestarts(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	var recent []time.Time
+	for _, t := range times {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	return recent
+}
+
+// cancelRestart stops any pending restart, and optionally forgets previous
+// restarts, such as when a new run is triggered by a change.
+func cancelRestart(forget bool) {
+	restartMu.Lock()
+	defer restartMu.Unlock()
+	if restartTimer != nil {
+		restartTimer.Stop()
+		restartTimer = nil
+	}
+	if forget {
+		restarts = nil
+	}
+}
+
+// superviseExit schedules a restart of a cmd that exited on its own,
+// according to the restart policy.
+func superviseExit(r *run) {
+	if r.exit.killed || !shouldRestart(restartPolicy, r.exitCode) {
+		return
+	}
+
+	restartMu.Lock()
+	defer restartMu.Unlock()
+
+	now := time.Now()
+	restarts = recentRestarts(restarts, now, restartWindow)
+	if len(restarts) >= maxRestarts {
+		prettyWriter.WriteStringf("cmd exited with code %s, giving up after %s restarts within %s\n",
+			color.RedString("%d", r.exitCode),
+			color.BlueString("%d", len(restarts)),
+			color.BlueString(restartWindow.String()))
+		return
+	}
+
+	delay := restartDelay(len(restarts))
+	restarts = append(restarts, now)
+	prettyWriter.WriteStringf("cmd exited with code %s, restarting in %s %s\n",
+		color.RedString("%d", r.exitCode),
+		color.BlueString(delay.String()),
+		color.HiBlackString("(restart %d/%d)", len(restarts), maxRestarts))
+
+	restartTimer = time.AfterFunc(delay, func() {
+		actions <- restartAction
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/kbirk/witch/writer"
+)
+
+const (
+	maxTailLines = 200
+)
+
+// tail retains the last lines of output of the current run.
+type tail struct {
+	mu    *sync.Mutex
+	lines []string
+	max   int
+}
+
+func newTail(max int) *tail {
+	return &tail{
+		mu:  &sync.Mutex{},
+		max: max,
+	}
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = append(t.lines, writer.StripANSI(string(p)))
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tail) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = nil
+}
+
+func (t *tail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.Join(t.lines, "")
+}
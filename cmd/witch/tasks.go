@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kbirk/witch/writer"
+)
+
+const (
+	// cmdTask is the task name of the cmd output when it is multiplexed.
+	cmdTask = "cmd"
+)
+
+var (
+	groupOutput bool
+	tasks       = writer.NewMux(console)
+	taskMu      = &sync.Mutex{}
+	taskCmds    = make(map[*exec.Cmd]struct{})
+	tasksKilled bool
+)
+
+// muxCmd returns whether the cmd output is written as a task of the mux,
+// either to be grouped or because hooks may write alongside it.
+func muxCmd() bool {
+	if groupOutput {
+		return true
+	}
+	hooks := []string{
+		preRunCmd,
+		postRunCmd,
+		onSuccessCmd,
+		onFailureCmd,
+		onFirstFailureCmd,
+		onRecoveryCmd,
+		onReadyCmd,
+		onAddedCmd,
+		onChangedCmd,
+		onRemovedCmd,
+	}
+	for _, hook := range hooks {
+		if hook != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// setupCmdWriter replaces the cmd writer with a task of the mux when
+// required. The cmd task is never closed, as the cmd writer is shared by
+// every run, and grouped output is written as each run is flushed.
+func setupCmdWriter() {
+	if !muxCmd() {
+		return
+	}
+	cmdWriter = tasks.Writer(cmdTask)
+	cmdWriter.Group(groupOutput)
+}
+
+// taskWriter returns a writer for the output of a task that may run
+// concurrently with the cmd, such as a hook or a per file cmd. It must be
+// closed once the task completes.
+func taskWriter(task string) *writer.CmdWriter {
+	w := tasks.Writer(task)
+	w.SetFormat(logFormat)
+	w.MaxTokenSize(maxTokenSize)
+	w.Group(groupOutput)
+	if outputRules != nil {
+		w.Rules(outputRules)
+	}
+	if apiServer != nil {
+		w.AddSink(outputSink{})
+	}
+	return w
+}
+
+// runTask runs the provided task cmd in its own process group, tracked so
+// that the entire group is killed on shutdown. If the timeout is non-zero the
+// group is killed once it elapses, returning whether or not it timed out.
+func runTask(c *exec.Cmd, timeout time.Duration) (bool, error) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	taskMu.Lock()
+	if tasksKilled {
+		taskMu.Unlock()
+		return false, fmt.Errorf("shutting down")
+	}
+	err := c.Start()
+	if err != nil {
+		taskMu.Unlock()
+		return false, err
+	}
+	taskCmds[c] = struct{}{}
+	taskMu.Unlock()
+
+	timedOut := false
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			taskMu.Lock()
+			defer taskMu.Unlock()
+			if _, ok := taskCmds[c]; !ok {
+				// already exited
+				return
+			}
+			timedOut = true
+			killTask(c)
+		})
+		defer timer.Stop()
+	}
+
+	err = c.Wait()
+
+	taskMu.Lock()
+	defer taskMu.Unlock()
+	delete(taskCmds, c)
+	return timedOut, err
+}
+
+func killTask(c *exec.Cmd) {
+	err := syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+	if err != nil {
+		prettyWriter.WriteErrorf("failed to kill task: %s\n", err)
+	}
+}
+
+// killTasks kills the process groups of all running task cmds, and prevents
+// any more from starting.
+func killTasks() {
+	taskMu.Lock()
+	defer taskMu.Unlock()
+
+	tasksKilled = true
+	for c := range taskCmds {
+		killTask(c)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/creack/pty"
+
+	"github.com/kbirk/witch/tty"
+)
+
+const (
+	// Ctrl-C
+	interruptKey = 0x03
+	// Ctrl-]
+	quitKey = 0x1d
+)
+
+var (
+	interactive   bool
+	terminalState *tty.State
+)
+
+func isTerminal(f *os.File) bool {
+	return tty.IsTerminal(int(f.Fd()))
+}
+
+func activePty() *os.File {
+	mu.Lock()
+	defer mu.Unlock()
+	if prev == nil {
+		return nil
+	}
+	return prev.pty
+}
+
+func startPty(r *run) (*os.File, error) {
+	if !isTerminal(os.Stdout) {
+		return pty.Start(r.cmd)
+	}
+	// match the size of the witch terminal
+	size, err := pty.GetsizeFull(os.Stdout)
+	if err != nil {
+		return pty.Start(r.cmd)
+	}
+	return pty.StartWithSize(r.cmd, size)
+}
+
+func forwardWindowSize() {
+	if !isTerminal(os.Stdout) {
+		return
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGWINCH)
+	go func() {
+		for range c {
+			f := activePty()
+			if f == nil {
+				continue
+			}
+			err := pty.InheritSize(os.Stdout, f)
+			if err != nil {
+				prettyWriter.WriteErrorf("failed to resize pty: %s\n", err)
+			}
+		}
+	}()
+}
+
+func restoreTerminal() {
+	if terminalState == nil {
+		return
+	}
+	err := tty.Restore(int(os.Stdin.Fd()), terminalState)
+	if err != nil {
+		prettyWriter.WriteErrorf("failed to restore terminal: %s\n", err)
+	}
+	terminalState = nil
+}
+
+// forwardStdin forwards terminal input to the cmd. Signal generating keys
+// are disabled so that Ctrl-C and the like reach the cmd rather than witch,
+// leaving Ctrl-] to quit witch, or Ctrl-C while no cmd is running.
+func forwardStdin() error {
+	state, err := tty.MakeRawNoSignals(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	terminalState = state
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			input := buf[:n]
+			quit := bytes.IndexByte(input, quitKey)
+			if quit != -1 {
+				input = input[:quit]
+			}
+			f := activePty()
+			if f == nil {
+				// no cmd to receive the input
+				if quit != -1 || bytes.IndexByte(input, interruptKey) != -1 {
+					actions <- quitAction
+					return
+				}
+				continue
+			}
+			_, err = f.Write(input)
+			if err != nil {
+				prettyWriter.WriteErrorf("failed to forward input: %s\n", err)
+			}
+			if quit != -1 {
+				actions <- quitAction
+				return
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/kbirk/witch/watcher"
+)
+
+var (
+	triggerMu = &sync.Mutex{}
+)
+
+func parseEventTypes(arg string) (map[watcher.EventType]bool, error) {
+	types := make(map[watcher.EventType]bool)
+	for _, str := range splitAndTrim(arg) {
+		switch str {
+		case watcher.Added.String():
+			types[watcher.Added] = true
+		case watcher.Changed.String():
+			types[watcher.Changed] = true
+		case watcher.Removed.String():
+			types[watcher.Removed] = true
+		default:
+			return nil, fmt.Errorf("unrecognized event type `%s`", str)
+		}
+	}
+	return types, nil
+}
+
+func filterEvents(events []watcher.Event, types map[watcher.EventType]bool) []watcher.Event {
+	var res []watcher.Event
+	for _, event := range events {
+		if types[event.Type] {
+			res = append(res, event)
+		}
+	}
+	return res
+}
+
+func triggerCmd(typ watcher.EventType) string {
+	switch typ {
+	case watcher.Added:
+		return onAddedCmd
+	case watcher.Changed:
+		return onChangedCmd
+	case watcher.Removed:
+		return onRemovedCmd
+	}
+	return ""
+}
+
+// splitTriggered separates the events that have an event type specific
+// command from the remaining events.
+func splitTriggered(events []watcher.Event) (map[watcher.EventType][]watcher.Event, []watcher.Event) {
+	triggered := make(map[watcher.EventType][]watcher.Event)
+	var remaining []watcher.Event
+	for _, event := range events {
+		if triggerCmd(event.Type) != "" {
+			triggered[event.Type] = append(triggered[event.Type], event)
+		} else {
+			remaining = append(remaining, event)
+		}
+	}
+	return triggered, remaining
+}
+
+func eventPaths(events []watcher.Event) []string {
+	paths := make([]string, 0, len(events))
+	for _, event := range events {
+		paths = append(paths, event.Path)
+	}
+	return paths
+}
+
+func runShell(task string, command string, env []string) error {
+	w := taskWriter(task)
+	c := exec.Command("/bin/sh", "-c", command)
+	c.Env = append(os.Environ(), env...)
+	c.Stdout = w
+	c.Stderr = w
+	_, err := runTask(c, 0)
+	w.Close()
+	return err
+}
+
+func executeTriggers(triggered map[watcher.EventType][]watcher.Event) {
+	// triggers are run one at a time, in the order they are detected
+	triggerMu.Lock()
+	defer triggerMu.Unlock()
+
+	for _, typ := range []watcher.EventType{watcher.Added, watcher.Changed, watcher.Removed} {
+		events, ok := triggered[typ]
+		if !ok {
+			continue
+		}
+		runHook(fmt.Sprintf("on-%s", typ), triggerCmd(typ), []string{
+			fmt.Sprintf("WITCH_EVENT=%s", typ),
+			fmt.Sprintf("WITCH_FILES=%s", strings.Join(eventPaths(events), "\n")),
+		})
+	}
+}
@@ -9,6 +9,12 @@ const (
 	ClearLine = "\x1b[2K"
 	// ClearToRight clears the screen from the cursor to the end of the line.
 	ClearToRight = "\x1b[0J"
+	// ClearScreen clears the entire screen.
+	ClearScreen = "\x1b[2J"
+	// ClearScrollback clears the scrollback buffer of the terminal.
+	ClearScrollback = "\x1b[3J"
+	// MoveCursorHome moves the cursor to the top left corner of the screen.
+	MoveCursorHome = "\x1b[H"
 	// MoveCursorLeft moves the cursor left one character.
 	MoveCursorLeft = "\x1b[D"
 	// MoveCursorUp moves the cursor up one line.
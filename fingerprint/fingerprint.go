@@ -0,0 +1,100 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Set represents the content hashes of a set of files, combined into a
+// single fingerprint. It is maintained incrementally as files change.
+type Set struct {
+	hashes map[string]string
+	mu     *sync.Mutex
+}
+
+// New instantiates and returns a new empty set.
+func New() *Set {
+	return &Set{
+		hashes: make(map[string]string),
+		mu:     &sync.Mutex{},
+	}
+}
+
+// Update hashes the current contents of the file at the provided path.
+// Directories are included by path only.
+func (s *Set) Update(path string) error {
+	hash, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hashes[path] = hash
+	return nil
+}
+
+// Remove removes the file at the provided path from the set.
+func (s *Set) Remove(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.hashes, path)
+}
+
+// Len returns the number of files in the set.
+func (s *Set) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.hashes)
+}
+
+// Sum returns the fingerprint of the paths and contents of the files in the
+// set.
+func (s *Set) Sum() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths := make([]string, 0, len(s.hashes))
+	for path := range s.hashes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, path := range paths {
+		io.WriteString(h, path)
+		h.Write([]byte{0})
+		io.WriteString(h, s.hashes[path])
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "dir", nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,52 @@
+package fingerprint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSumReflectsContents(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	s := New()
+	if err := s.Update(path); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	original := s.Sum()
+
+	// touching the file without changing it keeps the fingerprint
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	s.Update(path)
+	if s.Sum() != original {
+		t.Fatal("fingerprint changed without the contents changing")
+	}
+
+	if err := os.WriteFile(path, []byte("package main // edited\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	s.Update(path)
+	if s.Sum() == original {
+		t.Fatal("fingerprint unchanged after the contents changed")
+	}
+
+	// reverting the contents restores the fingerprint
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	s.Update(path)
+	if s.Sum() != original {
+		t.Fatal("fingerprint differs after the contents were reverted")
+	}
+
+	s.Remove(path)
+	if s.Len() != 0 || s.Sum() == original {
+		t.Fatal("fingerprint unchanged after the file was removed")
+	}
+}
@@ -6,10 +6,10 @@ require (
 	github.com/creack/pty v1.1.21
 	github.com/fatih/color v1.9.0
 	github.com/pkg/errors v0.9.1
+	golang.org/x/sys v0.15.0
 )
 
 require (
 	github.com/mattn/go-colorable v0.1.4 // indirect
 	github.com/mattn/go-isatty v0.0.11 // indirect
-	golang.org/x/sys v0.0.0-20191026070338-33540a1f6037 // indirect
 )
@@ -0,0 +1,78 @@
+package gotest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const listOutput = `
+{"ImportPath": "fmt", "Dir": "/go/src/fmt", "Name": "fmt", "Standard": true}
+{"ImportPath": "example.com/m/a", "Dir": "/m/a", "Name": "a", "Imports": ["fmt"]}
+{"ImportPath": "example.com/m/b", "Dir": "/m/b", "Name": "b", "Imports": ["example.com/m/a"]}
+{"ImportPath": "example.com/m/c", "Dir": "/m/c", "Name": "c"}
+{"ImportPath": "example.com/m/c [example.com/m/c.test]", "Dir": "/m/c", "Name": "c", "ForTest": "example.com/m/c", "Imports": ["example.com/m/b"]}
+{"ImportPath": "example.com/m/c.test", "Dir": "/m/c", "Name": "main", "Imports": ["example.com/m/c [example.com/m/c.test]"]}
+{"ImportPath": "example.com/m/d", "Dir": "/m/d", "Name": "d"}
+{"ImportPath": "example.com/dep", "Dir": "/go/pkg/mod/example.com/dep", "Name": "dep", "DepOnly": true}
+`
+
+func TestAffected(t *testing.T) {
+	g, err := Parse("/m", strings.NewReader(listOutput))
+	if err != nil {
+		t.Fatalf("failed to parse graph: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{name: "reverse deps", files: []string{"a/a.go"}, want: []string{"example.com/m/a", "example.com/m/b", "example.com/m/c"}},
+		{name: "test imports", files: []string{"/m/b/b.go"}, want: []string{"example.com/m/b", "example.com/m/c"}},
+		{name: "testdata", files: []string{"d/testdata/golden.txt"}, want: []string{"example.com/m/d"}},
+		{name: "unrelated", files: []string{"README.md"}, want: nil},
+		{name: "go.mod", files: []string{"go.mod"}, want: []string{"example.com/m/a", "example.com/m/b", "example.com/m/c", "example.com/m/d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := g.Affected(tt.files)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Affected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderer(t *testing.T) {
+	events := []string{
+		`{"Action":"run","Package":"p","Test":"TestA"}`,
+		`{"Action":"output","Package":"p","Test":"TestA","Output":"=== RUN   TestA\n"}`,
+		`{"Action":"pass","Package":"p","Test":"TestA"}`,
+		`{"Action":"output","Package":"p","Test":"TestB","Output":"    p_test.go:4: bad\n"}`,
+		`{"Action":"fail","Package":"p","Test":"TestB"}`,
+		`{"Action":"output","Package":"p","Output":"FAIL\n"}`,
+		`{"Action":"fail","Package":"p","Elapsed":0.5}`,
+		`# example.com/q`,
+	}
+	r := NewRenderer()
+	var lines []string
+	for _, e := range events {
+		lines = append(lines, r.Render(e)...)
+	}
+
+	want := []string{"    p_test.go:4: bad\n", "FAIL\tp\t0.500s\n", "# example.com/q"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Fatalf("rendered = %q, want %q", lines, want)
+	}
+	s := r.Summary()
+	if s.Passed != 1 || s.Failed != 1 || s.Packages != 1 || !reflect.DeepEqual(s.Failures, []string{"p.TestB"}) {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+
+	r.Reset()
+	if s := r.Summary(); s.Passed != 0 || len(s.Failures) != 0 {
+		t.Fatalf("summary after reset = %+v", s)
+	}
+}
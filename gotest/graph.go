@@ -0,0 +1,216 @@
+package gotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pkg represents the fields of `go list -json` used to build the graph.
+type pkg struct {
+	ImportPath string
+	Dir        string
+	Name       string
+	ForTest    string
+	Standard   bool
+	DepOnly    bool
+	Imports    []string
+}
+
+// Graph represents the reverse dependencies of the packages of a module,
+// including the imports of their tests.
+type Graph struct {
+	root    string
+	targets map[string]bool
+	dirs    map[string]string
+	rdeps   map[string][]string
+}
+
+// Load lists the packages matching the provided patterns within the provided
+// directory, along with their dependencies, and returns their graph.
+func Load(dir string, patterns ...string) (*Graph, error) {
+	args := append([]string{"list", "-deps", "-test", "-json"}, patterns...)
+	c := exec.Command("go", args...)
+	c.Dir = dir
+	stderr := &bytes.Buffer{}
+	c.Stderr = stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(abs, bytes.NewReader(out))
+}
+
+// Parse returns the graph of the packages output by `go list -deps -test
+// -json`, run within the provided directory.
+func Parse(root string, r io.Reader) (*Graph, error) {
+	g := &Graph{
+		root:    root,
+		targets: make(map[string]bool),
+		dirs:    make(map[string]string),
+		rdeps:   make(map[string][]string),
+	}
+	decoder := json.NewDecoder(r)
+	for {
+		var p pkg
+		err := decoder.Decode(&p)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.Standard {
+			continue
+		}
+		if p.Name == "main" && strings.HasSuffix(p.ImportPath, ".test") {
+			// generated test main
+			continue
+		}
+		// test variants are attributed to the package under test
+		target := p.ForTest
+		if target == "" {
+			target = stripVariant(p.ImportPath)
+			g.dirs[p.Dir] = target
+		}
+		if !p.DepOnly {
+			g.targets[target] = true
+		}
+		for _, imp := range p.Imports {
+			dep := stripVariant(imp)
+			if dep != target {
+				g.rdeps[dep] = append(g.rdeps[dep], target)
+			}
+		}
+	}
+	return g, nil
+}
+
+// All returns every testable package, sorted.
+func (g *Graph) All() []string {
+	res := make([]string, 0, len(g.targets))
+	for target := range g.targets {
+		res = append(res, target)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Affected returns the testable packages affected by changes to the provided
+// files, sorted. Changes to go.mod or go.sum affect every package.
+func (g *Graph) Affected(files []string) []string {
+	affected := make(map[string]bool)
+	var queue []string
+	for _, file := range files {
+		base := filepath.Base(file)
+		if base == "go.mod" || base == "go.sum" || base == "go.work" {
+			return g.All()
+		}
+		target, ok := g.packageOf(file)
+		if ok && !affected[target] {
+			affected[target] = true
+			queue = append(queue, target)
+		}
+	}
+	// walk the reverse dependencies
+	for len(queue) > 0 {
+		target := queue[0]
+		queue = queue[1:]
+		for _, rdep := range g.rdeps[target] {
+			if !affected[rdep] {
+				affected[rdep] = true
+				queue = append(queue, rdep)
+			}
+		}
+	}
+	var res []string
+	for target := range affected {
+		if g.targets[target] {
+			res = append(res, target)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// packageOf returns the package containing the file, such as a source file,
+// or an embedded or testdata file in a sub directory of the package.
+func (g *Graph) packageOf(file string) (string, bool) {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(g.root, file)
+	}
+	dir := filepath.Dir(file)
+	for {
+		if target, ok := g.dirs[dir]; ok {
+			return target, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir || !strings.HasPrefix(parent, g.root) {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+func stripVariant(importPath string) string {
+	// ex. `example.com/foo [example.com/foo.test]`
+	index := strings.IndexByte(importPath, ' ')
+	if index == -1 {
+		return importPath
+	}
+	return importPath[:index]
+}
+
+// PackageDir returns the directory of the provided package of the module
+// containing the provided directory, relative to that directory.
+func PackageDir(dir string, importPath string) (string, bool) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	root, module, ok := findModule(abs)
+	if !ok {
+		return "", false
+	}
+	if importPath != module && !strings.HasPrefix(importPath, module+"/") {
+		return "", false
+	}
+	pkgDir := filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(importPath, module)))
+	rel, err := filepath.Rel(abs, pkgDir)
+	if err != nil {
+		return "", false
+	}
+	return rel, true
+}
+
+// findModule returns the root directory and path of the module containing
+// the provided directory.
+func findModule(dir string) (string, string, bool) {
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) >= 2 && fields[0] == "module" {
+					return dir, strings.Trim(fields[1], `"`), true
+				}
+			}
+			return "", "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,128 @@
+package gotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// event represents a single event output by `go test -json`.
+type event struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// Summary represents the outcome of the tests of a run.
+type Summary struct {
+	Packages int
+	Passed   int
+	Failed   int
+	Skipped  int
+	Failures []string
+}
+
+// Renderer represents a renderer of `go test -json` output. The output of
+// passing tests is dropped, while the output of failing tests and packages is
+// written once they fail.
+type Renderer struct {
+	outputs map[string][]string
+	summary Summary
+	mu      *sync.Mutex
+}
+
+// NewRenderer instantiates and returns a new renderer.
+func NewRenderer() *Renderer {
+	return &Renderer{
+		outputs: make(map[string][]string),
+		mu:      &sync.Mutex{},
+	}
+}
+
+// Render returns the lines to write for the provided line of output. Lines
+// that are not test events, such as build errors, are returned as is.
+func (r *Renderer) Render(line string) []string {
+	var e event
+	err := json.Unmarshal([]byte(line), &e)
+	if err != nil || e.Action == "" {
+		return []string{line}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := e.Package + " " + e.Test
+	switch e.Action {
+	case "output":
+		r.outputs[key] = append(r.outputs[key], e.Output)
+		return nil
+	case "pass", "fail", "skip":
+	default:
+		return nil
+	}
+
+	output := r.outputs[key]
+	delete(r.outputs, key)
+
+	if e.Test != "" {
+		switch e.Action {
+		case "pass":
+			r.summary.Passed++
+		case "skip":
+			r.summary.Skipped++
+		case "fail":
+			r.summary.Failed++
+			r.summary.Failures = append(r.summary.Failures, fmt.Sprintf("%s.%s", e.Package, e.Test))
+			return output
+		}
+		return nil
+	}
+
+	switch e.Action {
+	case "pass":
+		r.summary.Packages++
+		return []string{fmt.Sprintf("ok  \t%s\t%.3fs\n", e.Package, e.Elapsed)}
+	case "fail":
+		r.summary.Packages++
+		// the package output includes any failures outside of a test
+		var res []string
+		for _, out := range output {
+			if !isPackageResult(out) {
+				res = append(res, out)
+			}
+		}
+		return append(res, fmt.Sprintf("FAIL\t%s\t%.3fs\n", e.Package, e.Elapsed))
+	}
+	// packages without test files
+	return nil
+}
+
+// Summary returns the summary of the rendered tests.
+func (r *Renderer) Summary() Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.summary
+	s.Failures = append([]string(nil), r.summary.Failures...)
+	return s
+}
+
+// Reset clears the rendered tests.
+func (r *Renderer) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.outputs = make(map[string][]string)
+	r.summary = Summary{}
+}
+
+func isPackageResult(out string) bool {
+	trimmed := strings.TrimSpace(out)
+	return trimmed == "PASS" ||
+		trimmed == "FAIL" ||
+		strings.HasPrefix(trimmed, "ok  \t") ||
+		strings.HasPrefix(trimmed, "FAIL\t")
+}
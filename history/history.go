@@ -0,0 +1,307 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	fileName = "history.jsonl"
+	// MaxOutputSize is the max number of bytes of output retained per run.
+	MaxOutputSize = 16 * 1024
+	// DefaultMaxRecords is the default max number of retained records.
+	DefaultMaxRecords = 200
+	// the longest record written is bounded by the retained output
+	maxRecordSize = 1024 * 1024
+	// the size of the chunks read from the end of the file by Tail
+	tailChunkSize = 64 * 1024
+)
+
+// Event represents a file event that triggered a run.
+type Event struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+}
+
+// Record represents a single completed run.
+type Record struct {
+	ID         int       `json:"id"`
+	Cmd        string    `json:"cmd"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	DurationMs int64     `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+	Outcome    string    `json:"outcome"`
+	Events     []Event   `json:"events,omitempty"`
+	Output     string    `json:"output,omitempty"`
+}
+
+// Store represents a persisted history of runs.
+type Store struct {
+	path       string
+	mu         *sync.Mutex
+	nextID     int
+	count      int
+	maxRecords int
+}
+
+// DefaultDir returns the default state directory for the provided project
+// directory, located within the user cache directory so that it is never
+// watched.
+func DefaultDir(project string) (string, error) {
+	abs, err := filepath.Abs(project)
+	if err != nil {
+		return "", err
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	dir := fmt.Sprintf("%s-%s", filepath.Base(abs), hex.EncodeToString(sum[:])[:12])
+	return filepath.Join(cache, "witch", dir), nil
+}
+
+// Open opens the history in the provided state directory, creating it if
+// necessary.
+func Open(dir string) (*Store, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		path:       filepath.Join(dir, fileName),
+		mu:         &sync.Mutex{},
+		nextID:     1,
+		maxRecords: DefaultMaxRecords,
+	}
+	records, err := s.Tail(1)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 {
+		s.nextID = records[len(records)-1].ID + 1
+	}
+	s.count, err = s.countRecords()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// MaxRecords sets the max number of retained records, unlimited if 0. Older
+// records are removed once the file exceeds the limit by a quarter, so that
+// the file is not rewritten on every run.
+func (s *Store) MaxRecords(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxRecords = n
+}
+
+// TruncateOutput returns the tail of the output, limited to the max output
+// size.
+func TruncateOutput(output string) string {
+	if len(output) <= MaxOutputSize {
+		return output
+	}
+	return output[len(output)-MaxOutputSize:]
+}
+
+// Append assigns an id to the provided record and persists it.
+func (s *Store) Append(rec Record) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec.ID = s.nextID
+	rec.Output = TruncateOutput(rec.Output)
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return rec, err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return rec, err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		return rec, err
+	}
+	s.nextID++
+	s.count++
+	if s.maxRecords > 0 && s.count > s.maxRecords+(s.maxRecords+3)/4 {
+		return rec, s.compact()
+	}
+	return rec, nil
+}
+
+// compact rewrites the file with only the most recent records retained.
+func (s *Store) compact() error {
+	records, err := s.Tail(s.maxRecords)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	for _, rec := range records {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+	// replace the file atomically so that no records are lost on failure
+	tmp := s.path + ".tmp"
+	err = os.WriteFile(tmp, b.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, s.path)
+	if err != nil {
+		return err
+	}
+	s.count = len(records)
+	return nil
+}
+
+// countRecords returns the number of lines of the file.
+func (s *Store) countRecords() (int, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	count := 0
+	buf := make([]byte, tailChunkSize)
+	for {
+		n, err := f.Read(buf)
+		count += bytes.Count(buf[:n], []byte{'\n'})
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// List returns all persisted records, oldest first.
+func (s *Store) List() ([]Record, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	for scanner.Scan() {
+		var rec Record
+		err := json.Unmarshal(scanner.Bytes(), &rec)
+		if err != nil {
+			// skip any partially written records
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// Tail returns up to the provided number of the most recent records, oldest
+// first. Only the end of the file is read.
+func (s *Store) Tail(n int) ([]Record, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// read chunks backwards until the last n lines are complete
+	offset := info.Size()
+	var data []byte
+	for offset > 0 && bytes.Count(data, []byte{'\n'}) <= n {
+		size := int64(tailChunkSize)
+		if size > offset {
+			size = offset
+		}
+		offset -= size
+		chunk := make([]byte, size)
+		_, err := f.ReadAt(chunk, offset)
+		if err != nil {
+			return nil, err
+		}
+		data = append(chunk, data...)
+	}
+	lines := bytes.Split(data, []byte{'\n'})
+	if offset > 0 {
+		// the first line may be partial
+		lines = lines[1:]
+	}
+	var records []Record
+	for _, line := range lines {
+		var rec Record
+		err := json.Unmarshal(line, &rec)
+		if err != nil {
+			// skip any partially written records
+			continue
+		}
+		records = append(records, rec)
+	}
+	if len(records) > n {
+		records = records[len(records)-n:]
+	}
+	return records, nil
+}
+
+// Get returns the record with the provided id.
+func (s *Store) Get(id int) (Record, error) {
+	records, err := s.List()
+	if err != nil {
+		return Record{}, err
+	}
+	for _, rec := range records {
+		if rec.ID == id {
+			return rec, nil
+		}
+	}
+	return Record{}, fmt.Errorf("no run with id %d", id)
+}
+
+// LastFailed returns the most recent record that did not succeed.
+func (s *Store) LastFailed() (Record, error) {
+	records, err := s.List()
+	if err != nil {
+		return Record{}, err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Outcome == "failure" || records[i].Outcome == "timeout" {
+			return records[i], nil
+		}
+	}
+	return Record{}, fmt.Errorf("no failed runs")
+}
@@ -0,0 +1,148 @@
+package history
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendListAndGet(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("failed to open history: %v", err)
+	}
+
+	start := time.Unix(1700000000, 0).UTC()
+	first, err := s.Append(Record{
+		Cmd:      "make",
+		Start:    start,
+		End:      start.Add(time.Second),
+		ExitCode: 2,
+		Outcome:  "failure",
+		Events:   []Event{{Type: "changed", Path: "main.go"}},
+		Output:   "main.go:1: syntax error\n",
+	})
+	if err != nil {
+		t.Fatalf("failed to append record: %v", err)
+	}
+	if _, err := s.Append(Record{Cmd: "make", Outcome: "success"}); err != nil {
+		t.Fatalf("failed to append record: %v", err)
+	}
+
+	// ids continue from the persisted records
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("failed to reopen history: %v", err)
+	}
+	third, err := s.Append(Record{Cmd: "make", Outcome: "success"})
+	if err != nil {
+		t.Fatalf("failed to append record: %v", err)
+	}
+	if first.ID != 1 || third.ID != 3 {
+		t.Fatalf("ids = %d, %d, want 1, 3", first.ID, third.ID)
+	}
+
+	records, err := s.List()
+	if err != nil {
+		t.Fatalf("failed to list records: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("len(records) = %d, want 3", len(records))
+	}
+
+	got, err := s.Get(1)
+	if err != nil {
+		t.Fatalf("failed to get record: %v", err)
+	}
+	if got.Output != first.Output || got.Events[0].Path != "main.go" || !got.Start.Equal(start) {
+		t.Fatalf("Get(1) = %#v, want %#v", got, first)
+	}
+
+	failed, err := s.LastFailed()
+	if err != nil {
+		t.Fatalf("failed to get last failed record: %v", err)
+	}
+	if failed.ID != 1 {
+		t.Fatalf("LastFailed().ID = %d, want 1", failed.ID)
+	}
+
+	if _, err := s.Get(42); err == nil {
+		t.Fatal("Get(42) succeeded, want error")
+	}
+}
+
+func TestTruncateOutput(t *testing.T) {
+	output := strings.Repeat("a", MaxOutputSize) + "tail"
+	got := TruncateOutput(output)
+	if len(got) != MaxOutputSize || !strings.HasSuffix(got, "tail") {
+		t.Fatalf("TruncateOutput() kept %d bytes ending %q, want %d ending %q", len(got), got[len(got)-4:], MaxOutputSize, "tail")
+	}
+}
+
+func TestRetentionAndTail(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("failed to open history: %v", err)
+	}
+	s.MaxRecords(4)
+
+	for i := 0; i < 12; i++ {
+		if _, err := s.Append(Record{Cmd: "make", Output: strings.Repeat("x", 100)}); err != nil {
+			t.Fatalf("failed to append record: %v", err)
+		}
+	}
+
+	records, err := s.List()
+	if err != nil {
+		t.Fatalf("failed to list records: %v", err)
+	}
+	if len(records) > 5 || records[len(records)-1].ID != 12 {
+		t.Fatalf("retained %d records ending with id %d, want at most 5 ending with id 12", len(records), records[len(records)-1].ID)
+	}
+
+	tail, err := s.Tail(2)
+	if err != nil {
+		t.Fatalf("failed to tail records: %v", err)
+	}
+	if len(tail) != 2 || tail[0].ID != 11 || tail[1].ID != 12 {
+		t.Fatalf("Tail(2) = %v, want ids 11 and 12", tail)
+	}
+
+	// ids continue after compaction
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("failed to reopen history: %v", err)
+	}
+	rec, err := s.Append(Record{Cmd: "make"})
+	if err != nil {
+		t.Fatalf("failed to append record: %v", err)
+	}
+	if rec.ID != 13 {
+		t.Fatalf("id = %d, want 13", rec.ID)
+	}
+}
+
+func TestTailReadsAcrossChunks(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open history: %v", err)
+	}
+	s.MaxRecords(0)
+
+	// each record is larger than half a chunk
+	output := strings.Repeat("y", MaxOutputSize)
+	for i := 0; i < 10; i++ {
+		if _, err := s.Append(Record{Cmd: "make", Output: output}); err != nil {
+			t.Fatalf("failed to append record: %v", err)
+		}
+	}
+	tail, err := s.Tail(6)
+	if err != nil {
+		t.Fatalf("failed to tail records: %v", err)
+	}
+	if len(tail) != 6 || tail[0].ID != 5 || tail[5].ID != 10 {
+		t.Fatalf("Tail(6) returned %d records, want ids 5 to 10", len(tail))
+	}
+}
@@ -0,0 +1,162 @@
+package livereload
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// ReloadMessage instructs clients to reload the page.
+	ReloadMessage = "reload"
+	// CSSMessage instructs clients to reload only their stylesheets.
+	CSSMessage = "css"
+)
+
+const script = `(function() {
+	var src = document.currentScript.src;
+	var url = src.substring(0, src.lastIndexOf('/')) + '/livereload';
+	var source = new EventSource(url);
+	source.addEventListener('css', function() {
+		var links = document.querySelectorAll('link[rel="stylesheet"]');
+		for (var i = 0; i < links.length; i++) {
+			var href = links[i].href.replace(/[?&]livereload=\d+/, '');
+			var sep = href.indexOf('?') === -1 ? '?' : '&';
+			links[i].href = href + sep + 'livereload=' + Date.now();
+		}
+	});
+	source.addEventListener('reload', function() {
+		window.location.reload();
+	});
+})();
+`
+
+// Server represents a live reload server that notifies connected browsers
+// when they should reload.
+type Server struct {
+	listener net.Listener
+	mu       *sync.Mutex
+	clients  map[chan string]struct{}
+}
+
+// New instantiates and returns a new live reload server.
+func New() *Server {
+	return &Server{
+		mu:      &sync.Mutex{},
+		clients: make(map[chan string]struct{}),
+	}
+}
+
+// IsCSSOnly returns whether or not all of the provided paths are stylesheets,
+// in which case the page does not need to be reloaded.
+func IsCSSOnly(paths []string) bool {
+	if len(paths) == 0 {
+		return false
+	}
+	for _, path := range paths {
+		if strings.ToLower(filepath.Ext(path)) != ".css" {
+			return false
+		}
+	}
+	return true
+}
+
+// Listen starts serving on the provided address.
+func (s *Server) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	go http.Serve(listener, s.Handler())
+	return nil
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// Handler returns the HTTP handler serving the client script and the reload
+// stream.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/livereload.js", s.handleScript)
+	mux.HandleFunc("/livereload", s.handleStream)
+	return mux
+}
+
+// Reload notifies all connected clients to reload the page.
+func (s *Server) Reload() {
+	s.broadcast(ReloadMessage)
+}
+
+// ReloadCSS notifies all connected clients to reload their stylesheets.
+func (s *Server) ReloadCSS() {
+	s.broadcast(CSSMessage)
+}
+
+// NumClients returns the number of connected clients.
+func (s *Server) NumClients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+func (s *Server) broadcast(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client := range s.clients {
+		select {
+		case client <- msg:
+		default:
+			// a reload is already pending for this client
+		}
+	}
+}
+
+func (s *Server) handleScript(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/javascript")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(script))
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan string, 1)
+	s.mu.Lock()
+	s.clients[client] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case msg := <-client:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg, msg)
+			flusher.Flush()
+		}
+	}
+}
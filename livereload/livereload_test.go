@@ -0,0 +1,67 @@
+package livereload
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsCSSOnly(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  bool
+	}{
+		{paths: nil, want: false},
+		{paths: []string{"styles/main.css", "styles/THEME.CSS"}, want: true},
+		{paths: []string{"styles/main.css", "index.html"}, want: false},
+	}
+
+	for _, tt := range tests {
+		got := IsCSSOnly(tt.paths)
+		if got != tt.want {
+			t.Fatalf("IsCSSOnly(%#v) = %t, want %t", tt.paths, got, tt.want)
+		}
+	}
+}
+
+func TestReloadIsStreamedToClients(t *testing.T) {
+	s := New()
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/livereload.js")
+	if err != nil {
+		t.Fatalf("GET /livereload.js failed: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET /livereload.js status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	res, err = http.Get(ts.URL + "/livereload")
+	if err != nil {
+		t.Fatalf("GET /livereload failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for s.NumClients() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("client was never registered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	s.ReloadCSS()
+
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read event: %v", err)
+	}
+	if strings.TrimSpace(line) != "event: css" {
+		t.Fatalf("event line = %q, want %q", line, "event: css\n")
+	}
+}
@@ -0,0 +1,153 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/kbirk/witch/writer"
+)
+
+const (
+	dialTimeout    = 500 * time.Millisecond
+	requestTimeout = 2 * time.Second
+)
+
+// Probe represents a check of whether or not a process is ready.
+type Probe interface {
+	// Ready returns whether or not the process is ready.
+	Ready() bool
+	// String returns a description of the probe.
+	String() string
+}
+
+type tcpProbe struct {
+	addr string
+}
+
+// TCP returns a probe that is ready once the provided address accepts
+// connections.
+func TCP(addr string) Probe {
+	return &tcpProbe{
+		addr: addr,
+	}
+}
+
+func (p *tcpProbe) Ready() bool {
+	conn, err := net.DialTimeout("tcp", p.addr, dialTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func (p *tcpProbe) String() string {
+	return fmt.Sprintf("tcp %s", p.addr)
+}
+
+type httpProbe struct {
+	url    string
+	client *http.Client
+}
+
+// HTTP returns a probe that is ready once the provided url responds with a
+// 200 status.
+func HTTP(url string) Probe {
+	return &httpProbe{
+		url: url,
+		client: &http.Client{
+			Timeout: requestTimeout,
+		},
+	}
+}
+
+func (p *httpProbe) Ready() bool {
+	res, err := p.client.Get(p.url)
+	if err != nil {
+		return false
+	}
+	res.Body.Close()
+	return res.StatusCode == http.StatusOK
+}
+
+func (p *httpProbe) String() string {
+	return fmt.Sprintf("http %s", p.url)
+}
+
+// Pattern represents a probe that is ready once a line of output matches a
+// regular expression. Output is provided by writing to it.
+type Pattern struct {
+	regex   *regexp.Regexp
+	mu      *sync.Mutex
+	matched bool
+}
+
+// NewPattern instantiates and returns a new pattern probe.
+func NewPattern(expr string) (*Pattern, error) {
+	regex, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Pattern{
+		regex: regex,
+		mu:    &sync.Mutex{},
+	}, nil
+}
+
+// Write implements the standard Write interface.
+func (p *Pattern) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.matched && p.regex.MatchString(writer.StripANSI(string(b))) {
+		p.matched = true
+	}
+	return len(b), nil
+}
+
+// Reset clears any previous match.
+func (p *Pattern) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.matched = false
+}
+
+// Ready returns whether or not the pattern has been matched.
+func (p *Pattern) Ready() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.matched
+}
+
+func (p *Pattern) String() string {
+	return fmt.Sprintf("pattern %s", p.regex)
+}
+
+// Wait blocks until all of the provided probes are ready, or the context is
+// done.
+func Wait(ctx context.Context, probes []Probe, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pending := probes
+	for {
+		var remaining []Probe
+		for _, p := range pending {
+			if !p.Ready() {
+				remaining = append(remaining, p)
+			}
+		}
+		if len(remaining) == 0 {
+			return nil
+		}
+		pending = remaining
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s not ready: %s", pending[0], ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
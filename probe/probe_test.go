@@ -0,0 +1,64 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPattern(t *testing.T) {
+	p, err := NewPattern(`listening on :\d+`)
+	if err != nil {
+		t.Fatalf("failed to create pattern: %v", err)
+	}
+	p.Write([]byte("starting server\n"))
+	if p.Ready() {
+		t.Fatal("Ready() = true before match, want false")
+	}
+	p.Write([]byte("\x1b[32mlistening on :8080\x1b[0m\n"))
+	if !p.Ready() {
+		t.Fatal("Ready() = false after match, want true")
+	}
+	p.Reset()
+	if p.Ready() {
+		t.Fatal("Ready() = true after reset, want false")
+	}
+}
+
+func TestTCPAndHTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	if !TCP(ts.Listener.Addr().String()).Ready() {
+		t.Fatal("TCP probe of listening server not ready, want ready")
+	}
+	if !HTTP(ts.URL + "/healthz").Ready() {
+		t.Fatal("HTTP probe of healthy url not ready, want ready")
+	}
+	if HTTP(ts.URL + "/").Ready() {
+		t.Fatal("HTTP probe of unhealthy url ready, want not ready")
+	}
+}
+
+func TestWaitTimesOut(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = Wait(ctx, []Probe{TCP(addr)}, 10*time.Millisecond)
+	if err == nil {
+		t.Fatal("Wait() on closed port succeeded, want error")
+	}
+}
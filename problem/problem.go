@@ -0,0 +1,313 @@
+package problem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kbirk/witch/writer"
+)
+
+var (
+	builtins = map[string]Matcher{
+		"go": {
+			Name: "go",
+			Patterns: []*regexp.Regexp{
+				// compiler and vet diagnostics, ex. `./main.go:12:2: undefined: foo`
+				regexp.MustCompile(`^(?:vet: )?(?P<file>[^\s:]+\.go):(?P<line>\d+):(?:(?P<col>\d+):)?\s+(?P<message>.+)$`),
+			},
+		},
+		"gotest": {
+			Name: "gotest",
+			Patterns: []*regexp.Regexp{
+				// test failures, ex. `    main_test.go:12: got 1, want 2`
+				regexp.MustCompile(`^\s+(?P<file>[^\s:]+\.go):(?P<line>\d+):\s+(?P<message>.+)$`),
+			},
+			// test files are relative to the package, which is reported
+			// once its tests complete, ex. `FAIL	example.com/pkg	0.012s`
+			PackagePattern: regexp.MustCompile(`^(?:FAIL|ok)\s+(?P<package>[^\s\[]+)`),
+		},
+		"tsc": {
+			Name: "tsc",
+			Patterns: []*regexp.Regexp{
+				// ex. `src/app.ts(12,5): error TS2322: Type 'string' is not assignable`
+				regexp.MustCompile(`^(?P<file>[^\s(]+)\((?P<line>\d+),(?P<col>\d+)\):\s+(?P<severity>error|warning)\s+(?P<message>.+)$`),
+				// ex. `src/app.ts:12:5 - error TS2322: Type 'string' is not assignable`
+				regexp.MustCompile(`^(?P<file>[^\s:]+):(?P<line>\d+):(?P<col>\d+) - (?P<severity>error|warning)\s+(?P<message>.+)$`),
+			},
+		},
+		"eslint": {
+			Name: "eslint",
+			// the stylish format lists the file once, followed by its problems
+			FilePattern: regexp.MustCompile(`^(?P<file>\S+\.(?:[cm]?jsx?|tsx?|vue|svelte))$`),
+			Patterns: []*regexp.Regexp{
+				// ex. `  12:5  error  'foo' is not defined  no-undef`
+				regexp.MustCompile(`^\s+(?P<line>\d+):(?P<col>\d+)\s+(?P<severity>error|warning)\s+(?P<message>.+?)(?:\s{2,}\S+)?$`),
+				// ex. `src/app.js:12:5: 'foo' is not defined [Error/no-undef]`
+				regexp.MustCompile(`^(?P<file>[^\s:]+):(?P<line>\d+):(?P<col>\d+):\s+(?P<message>.+?)\s+\[(?P<severity>Error|Warning)/\S+\]$`),
+			},
+		},
+		"gcc": {
+			Name: "gcc",
+			Patterns: []*regexp.Regexp{
+				// ex. `main.c:12:5: error: expected ';' before '}' token`
+				regexp.MustCompile(`^(?P<file>[^\s:]+):(?P<line>\d+):(?P<col>\d+):\s+(?P<severity>fatal error|error|warning|note):\s+(?P<message>.+)$`),
+			},
+		},
+	}
+)
+
+// Problem represents a single diagnostic extracted from the cmd output.
+type Problem struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Col      int    `json:"col,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Matcher  string `json:"matcher"`
+
+	// unresolved is set while the file is relative to an unknown package
+	unresolved bool
+}
+
+// String returns the problem in the errorformat understood by vim and emacs.
+func (p Problem) String() string {
+	if p.Col > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s: %s", p.File, p.Line, p.Col, p.Severity, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", p.File, p.Line, p.Severity, p.Message)
+}
+
+// Matcher represents a set of patterns extracting problems from lines of
+// output. Patterns use the named groups `file`, `line`, `col`, `severity` and
+// `message`. A pattern without a file group uses the file of the last line
+// matching the file pattern. A matcher with a package pattern reports files
+// relative to the package named by the next line matching it.
+type Matcher struct {
+	Name           string
+	FilePattern    *regexp.Regexp
+	PackagePattern *regexp.Regexp
+	Patterns       []*regexp.Regexp
+}
+
+// Builtins returns the names of the built-in matchers.
+func Builtins() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Builtin returns the built-in matcher of the provided name.
+func Builtin(name string) (Matcher, bool) {
+	m, ok := builtins[name]
+	return m, ok
+}
+
+// NewMatcher returns a matcher for the provided user pattern, which must
+// include at least the `file` and `line` named groups.
+func NewMatcher(name string, pattern string) (Matcher, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Matcher{}, err
+	}
+	if re.SubexpIndex("file") == -1 || re.SubexpIndex("line") == -1 {
+		return Matcher{}, fmt.Errorf("pattern `%s` requires the named groups file and line", pattern)
+	}
+	return Matcher{
+		Name:     name,
+		Patterns: []*regexp.Regexp{re},
+	}, nil
+}
+
+// Collector represents a writer collecting the problems from each line of
+// the written output. It is intended to be added as a sink of a cmd writer.
+type Collector struct {
+	matchers []Matcher
+	files    []string
+	pending  [][]Problem
+	problems []Problem
+	seen     map[string]bool
+	resolve  func(pkg string) (string, bool)
+	mu       *sync.Mutex
+}
+
+// NewCollector instantiates and returns a new collector using the provided
+// matchers.
+func NewCollector(matchers ...Matcher) *Collector {
+	return &Collector{
+		matchers: matchers,
+		files:    make([]string, len(matchers)),
+		pending:  make([][]Problem, len(matchers)),
+		seen:     make(map[string]bool),
+		mu:       &sync.Mutex{},
+	}
+}
+
+// ResolvePackages sets the func returning the directory of a package, used
+// to resolve the files of matchers with a package pattern.
+func (c *Collector) ResolvePackages(resolve func(pkg string) (string, bool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resolve = resolve
+}
+
+// Write implements the standard Write interface. Each write is expected to
+// be a single line.
+func (c *Collector) Write(p []byte) (int, error) {
+	line := strings.TrimRight(writer.StripANSI(string(p)), "\r\n")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, m := range c.matchers {
+		if m.FilePattern != nil {
+			if match := m.FilePattern.FindStringSubmatch(line); match != nil {
+				c.files[i] = group(m.FilePattern, match, "file")
+				continue
+			}
+		}
+		if m.PackagePattern != nil {
+			if match := m.PackagePattern.FindStringSubmatch(line); match != nil {
+				c.resolvePending(i, group(m.PackagePattern, match, "package"))
+				continue
+			}
+		}
+		for _, pattern := range m.Patterns {
+			match := pattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			prob, ok := newProblem(m.Name, pattern, match, c.files[i])
+			if ok {
+				if m.PackagePattern != nil {
+					prob.unresolved = true
+					c.pending[i] = append(c.pending[i], prob)
+					return len(p), nil
+				}
+				c.add(prob)
+				// a line is only ever a single problem
+				return len(p), nil
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// Problems returns the problems collected since the last reset. Problems
+// whose package has yet to be reported are included with their unresolved
+// files.
+func (c *Collector) Problems() []Problem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := make([]Problem, len(c.problems))
+	copy(res, c.problems)
+	for _, pending := range c.pending {
+		res = append(res, pending...)
+	}
+	return res
+}
+
+// Reset clears the collected problems.
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.problems = nil
+	c.files = make([]string, len(c.matchers))
+	c.pending = make([][]Problem, len(c.matchers))
+	c.seen = make(map[string]bool)
+}
+
+func (c *Collector) add(p Problem) {
+	key := p.String()
+	if c.seen[key] {
+		// the same problem may be reported by multiple packages
+		return
+	}
+	c.seen[key] = true
+	c.problems = append(c.problems, p)
+}
+
+// resolvePending resolves the files of the pending problems of the matcher
+// against the directory of the provided package.
+func (c *Collector) resolvePending(i int, pkg string) {
+	pending := c.pending[i]
+	c.pending[i] = nil
+	if len(pending) == 0 {
+		return
+	}
+	dir, ok := "", false
+	if c.resolve != nil {
+		dir, ok = c.resolve(pkg)
+	}
+	for _, p := range pending {
+		if ok {
+			p.File = filepath.Join(dir, p.File)
+			p.unresolved = false
+		}
+		c.add(p)
+	}
+}
+
+// WriteErrorfile writes the problems to the provided path, one per line, in
+// the errorformat understood by vim and emacs. Problems whose files could not
+// be resolved are omitted, as they would not locate the file.
+func WriteErrorfile(path string, problems []Problem) error {
+	var b strings.Builder
+	for _, p := range problems {
+		if p.unresolved {
+			continue
+		}
+		b.WriteString(p.String())
+		b.WriteString("\n")
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+func group(re *regexp.Regexp, match []string, name string) string {
+	index := re.SubexpIndex(name)
+	if index == -1 {
+		return ""
+	}
+	return match[index]
+}
+
+func newProblem(matcher string, re *regexp.Regexp, match []string, file string) (Problem, bool) {
+	if f := group(re, match, "file"); f != "" {
+		file = f
+	}
+	if file == "" {
+		return Problem{}, false
+	}
+	line, err := strconv.Atoi(group(re, match, "line"))
+	if err != nil {
+		return Problem{}, false
+	}
+	col, _ := strconv.Atoi(group(re, match, "col"))
+	severity := strings.ToLower(group(re, match, "severity"))
+	if severity == "" {
+		severity = "error"
+	}
+	message := group(re, match, "message")
+	if message == "" {
+		message = strings.TrimSpace(match[0])
+	}
+	return Problem{
+		File:     filepath.Clean(file),
+		Line:     line,
+		Col:      col,
+		Severity: severity,
+		Message:  strings.TrimSpace(message),
+		Matcher:  matcher,
+	}, true
+}
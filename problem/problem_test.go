@@ -0,0 +1,160 @@
+package problem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func builtinMatchers(t *testing.T, names ...string) []Matcher {
+	t.Helper()
+	var matchers []Matcher
+	for _, name := range names {
+		m, ok := Builtin(name)
+		if !ok {
+			t.Fatalf("missing builtin matcher %s", name)
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers
+}
+
+func TestBuiltinMatchers(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher string
+		lines   []string
+		want    string
+	}{
+		{
+			name:    "go",
+			matcher: "go",
+			lines:   []string{"./cmd/main.go:12:2: undefined: foo"},
+			want:    "cmd/main.go:12:2: error: undefined: foo",
+		},
+		{
+			name:    "gotest",
+			matcher: "gotest",
+			lines:   []string{"--- FAIL: TestFoo (0.00s)", "    foo_test.go:8: got 1, want 2"},
+			want:    "foo_test.go:8: error: got 1, want 2",
+		},
+		{
+			name:    "tsc",
+			matcher: "tsc",
+			lines:   []string{"src/app.ts(3,7): error TS2322: Type 'string' is not assignable to type 'number'."},
+			want:    "src/app.ts:3:7: error: TS2322: Type 'string' is not assignable to type 'number'.",
+		},
+		{
+			name:    "tsc pretty",
+			matcher: "tsc",
+			lines:   []string{"\x1b[96msrc/app.ts\x1b[0m:\x1b[93m3\x1b[0m:\x1b[93m7\x1b[0m - \x1b[91merror\x1b[0m TS2322: Type mismatch."},
+			want:    "src/app.ts:3:7: error: TS2322: Type mismatch.",
+		},
+		{
+			name:    "eslint stylish",
+			matcher: "eslint",
+			lines:   []string{"/src/app.js", "  4:10  warning  'x' is assigned a value but never used  no-unused-vars"},
+			want:    "/src/app.js:4:10: warning: 'x' is assigned a value but never used",
+		},
+		{
+			name:    "gcc",
+			matcher: "gcc",
+			lines:   []string{"main.c:5:3: fatal error: foo.h: No such file or directory"},
+			want:    "main.c:5:3: fatal error: foo.h: No such file or directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector(builtinMatchers(t, tt.matcher)...)
+			for _, line := range tt.lines {
+				c.Write([]byte(line + "\n"))
+			}
+			problems := c.Problems()
+			if len(problems) != 1 {
+				t.Fatalf("len(problems) = %d, want 1: %v", len(problems), problems)
+			}
+			if problems[0].String() != tt.want {
+				t.Fatalf("problem = %q, want %q", problems[0].String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectorDeduplicatesAndResets(t *testing.T) {
+	m, err := NewMatcher("custom", `^(?P<file>\S+) line (?P<line>\d+): (?P<message>.+)$`)
+	if err != nil {
+		t.Fatalf("failed to create matcher: %v", err)
+	}
+	c := NewCollector(m)
+	c.Write([]byte("a.txt line 3: bad\n"))
+	c.Write([]byte("a.txt line 3: bad\n"))
+	c.Write([]byte("unrelated\n"))
+	if got := len(c.Problems()); got != 1 {
+		t.Fatalf("len(problems) = %d, want 1", got)
+	}
+
+	c.Reset()
+	if got := len(c.Problems()); got != 0 {
+		t.Fatalf("len(problems) after reset = %d, want 0", got)
+	}
+
+	if _, err := NewMatcher("custom", `^(?P<message>.+)$`); err == nil {
+		t.Fatal("expected an error for a pattern without file and line groups")
+	}
+}
+
+func TestWriteErrorfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.err")
+	err := WriteErrorfile(path, []Problem{
+		{File: "main.go", Line: 1, Col: 2, Severity: "error", Message: "bad"},
+		{File: "main_test.go", Line: 3, Severity: "error", Message: "worse"},
+	})
+	if err != nil {
+		t.Fatalf("failed to write errorfile: %v", err)
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read errorfile: %v", err)
+	}
+	want := "main.go:1:2: error: bad\nmain_test.go:3: error: worse\n"
+	if string(contents) != want {
+		t.Fatalf("errorfile = %q, want %q", contents, want)
+	}
+}
+
+func TestCollectorResolvesPackages(t *testing.T) {
+	c := NewCollector(builtinMatchers(t, "gotest")...)
+	c.ResolvePackages(func(pkg string) (string, bool) {
+		if pkg == "example.com/mod/foo" {
+			return "foo", true
+		}
+		return "", false
+	})
+	c.Write([]byte("    foo_test.go:8: got 1, want 2\n"))
+	c.Write([]byte("FAIL\texample.com/mod/foo\t0.012s\n"))
+	c.Write([]byte("    foo_test.go:8: got 1, want 2\n"))
+	c.Write([]byte("FAIL\texample.com/other/foo\t0.012s\n"))
+	c.Write([]byte("    bar_test.go:4: pending\n"))
+
+	problems := c.Problems()
+	if len(problems) != 3 {
+		t.Fatalf("len(problems) = %d, want 3: %v", len(problems), problems)
+	}
+	if got, want := problems[0].File, filepath.Join("foo", "foo_test.go"); got != want {
+		t.Fatalf("resolved file = %q, want %q", got, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "errors.err")
+	if err := WriteErrorfile(path, problems); err != nil {
+		t.Fatalf("failed to write errorfile: %v", err)
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read errorfile: %v", err)
+	}
+	want := filepath.Join("foo", "foo_test.go") + ":8: error: got 1, want 2\n"
+	if string(contents) != want {
+		t.Fatalf("errorfile = %q, want %q", contents, want)
+	}
+}
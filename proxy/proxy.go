@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dialInterval = 100 * time.Millisecond
+	dialTimeout  = 100 * time.Millisecond
+)
+
+var (
+	errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; background: #1e1e2e; color: #cdd6f4; font-family: sans-serif; }
+header { padding: 16px 24px; background: #f38ba8; color: #1e1e2e; }
+h1 { margin: 0; font-size: 20px; }
+pre { margin: 0; padding: 24px; font-family: monospace; font-size: 13px; white-space: pre-wrap; word-break: break-all; }
+</style>
+</head>
+<body>
+<header><h1>{{.Title}}</h1></header>
+<pre>{{.Output}}</pre>
+</body>
+</html>
+`))
+)
+
+// Proxy represents a reverse proxy that holds requests while the target is
+// restarting.
+type Proxy struct {
+	target   string
+	timeout  time.Duration
+	reverse  *httputil.ReverseProxy
+	listener net.Listener
+	mu       *sync.Mutex
+	gated    bool
+	ready    bool
+	failed   bool
+	output   string
+	changed  chan struct{}
+}
+
+// New instantiates and returns a new proxy to the provided target address.
+// Requests are held for up to the provided timeout for the target to accept
+// connections.
+func New(target string, timeout time.Duration) (*Proxy, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	target = net.JoinHostPort(host, port)
+	u, err := url.Parse(fmt.Sprintf("http://%s", target))
+	if err != nil {
+		return nil, err
+	}
+	return &Proxy{
+		target:  target,
+		timeout: timeout,
+		reverse: httputil.NewSingleHostReverseProxy(u),
+		mu:      &sync.Mutex{},
+		changed: make(chan struct{}),
+	}, nil
+}
+
+// Listen starts serving on the provided address.
+func (p *Proxy) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	p.listener = listener
+	go http.Serve(listener, p)
+	return nil
+}
+
+// Close stops the proxy.
+func (p *Proxy) Close() error {
+	if p.listener == nil {
+		return nil
+	}
+	return p.listener.Close()
+}
+
+// RequireReady sets that requests are only forwarded once the target has been
+// flagged as ready, rather than as soon as it accepts connections.
+func (p *Proxy) RequireReady() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gated = true
+}
+
+// Ready flags that the target is ready to receive requests.
+func (p *Proxy) Ready() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ready = true
+	p.notify()
+}
+
+// Restart flags that the target is restarting, requests will be held until
+// it accepts connections.
+func (p *Proxy) Restart() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ready = false
+	p.failed = false
+	p.output = ""
+	p.notify()
+}
+
+// Fail flags that the target failed to start, requests will be responded to
+// with an error page containing the provided output.
+func (p *Proxy) Fail(output string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failed = true
+	p.output = output
+	p.notify()
+}
+
+func (p *Proxy) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+func (p *Proxy) state() (bool, string, chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.failed, p.output, p.changed
+}
+
+func (p *Proxy) held() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.gated && !p.ready
+}
+
+func (p *Proxy) accepting() bool {
+	conn, err := net.DialTimeout("tcp", p.target, dialTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// wait blocks until the target accepts connections, returning false if the
+// target has failed or the request is done.
+func (p *Proxy) wait(ctx context.Context) bool {
+	ticker := time.NewTicker(dialInterval)
+	defer ticker.Stop()
+	for {
+		failed, _, changed := p.state()
+		if failed {
+			return false
+		}
+		if !p.held() && p.accepting() {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Proxy) writeError(w http.ResponseWriter, status int, title string, output string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	errorPage.Execute(w, struct {
+		Title  string
+		Output string
+	}{
+		Title:  title,
+		Output: strings.TrimRight(output, "\n"),
+	})
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), p.timeout)
+	defer cancel()
+
+	if !p.wait(ctx) {
+		failed, output, _ := p.state()
+		if failed {
+			p.writeError(w, http.StatusBadGateway, "Command failed", output)
+			return
+		}
+		p.writeError(w, http.StatusGatewayTimeout, "Timed out waiting for target", fmt.Sprintf("%s is not accepting connections", p.target))
+		return
+	}
+	p.reverse.ServeHTTP(w, req)
+}
@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyHoldsRequestsUntilTargetAccepts(t *testing.T) {
+	// reserve a port for the target
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	target := listener.Addr().String()
+	listener.Close()
+
+	p, err := New(target, 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	ts := httptest.NewServer(p)
+	defer ts.Close()
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		res, err := http.Get(ts.URL)
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		results <- result{body: string(body), err: err}
+	}()
+
+	// start the target after the request has been made
+	time.Sleep(200 * time.Millisecond)
+	listener, err = net.Listen("tcp", target)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", target, err)
+	}
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer listener.Close()
+
+	select {
+	case res := <-results:
+		if res.err != nil {
+			t.Fatalf("request failed: %v", res.err)
+		}
+		if res.body != "hello" {
+			t.Fatalf("body = %q, want %q", res.body, "hello")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was never forwarded")
+	}
+}
+
+func TestProxyServesErrorPageOnFailure(t *testing.T) {
+	p, err := New("127.0.0.1:1", time.Second)
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	p.Fail("main.go:3: undefined: <foo>")
+
+	ts := httptest.NewServer(p)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if res.StatusCode != http.StatusBadGateway {
+		t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusBadGateway)
+	}
+	if !strings.Contains(string(body), "main.go:3: undefined: &lt;foo&gt;") {
+		t.Fatalf("body = %q, want escaped output", body)
+	}
+}
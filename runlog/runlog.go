@@ -0,0 +1,256 @@
+package runlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kbirk/witch/writer"
+)
+
+const (
+	// LatestName is the name of the symlink to the most recent log file.
+	LatestName = "latest.log"
+	fileExt    = ".log"
+	timeFormat = "20060102-150405.000"
+)
+
+var (
+	// fileName matches the names of the log files created by a dir, with an
+	// index suffix for runs started within the same millisecond.
+	fileName = regexp.MustCompile(`^(\d{8}-\d{6}\.\d{3})(?:_(\d+))?\.log$`)
+)
+
+// logFile represents a log file of the dir, ordered by its name.
+type logFile struct {
+	path  string
+	base  string
+	index int
+}
+
+// Dir represents a directory of log files, one per run. It is intended to be
+// added as a sink of a cmd writer.
+type Dir struct {
+	path      string
+	maxFiles  int
+	maxBytes  int64
+	stripANSI bool
+	file      *os.File
+	mu        *sync.Mutex
+}
+
+// Open opens the log directory at the provided path, creating it if
+// necessary.
+func Open(path string) (*Dir, error) {
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &Dir{
+		path: path,
+		mu:   &sync.Mutex{},
+	}, nil
+}
+
+// MaxFiles sets the max number of log files retained, unlimited if 0.
+func (d *Dir) MaxFiles(n int) {
+	d.maxFiles = n
+}
+
+// MaxBytes sets the max total size of the retained log files, unlimited if 0.
+func (d *Dir) MaxBytes(n int64) {
+	d.maxBytes = n
+}
+
+// StripANSI sets whether or not ANSI escape sequences are removed from the
+// written output.
+func (d *Dir) StripANSI(strip bool) {
+	d.stripANSI = strip
+}
+
+// Start closes any current log file and opens a new one for a run started at
+// the provided time, returning its path.
+func (d *Dir) Start(start time.Time) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.close()
+
+	file, err := d.create(start)
+	if err != nil {
+		return "", err
+	}
+	d.file = file
+
+	err = d.link(filepath.Base(file.Name()))
+	if err != nil {
+		return file.Name(), err
+	}
+	return file.Name(), d.prune()
+}
+
+// Stop closes the current log file and removes any log files exceeding the
+// retention limits.
+func (d *Dir) Stop() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	err := d.close()
+	if err != nil {
+		return err
+	}
+	return d.prune()
+}
+
+// Write implements the standard Write interface. Output written while no
+// run is started is discarded.
+func (d *Dir) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return len(p), nil
+	}
+	str := string(p)
+	if d.stripANSI {
+		str = writer.StripANSI(str)
+	}
+	_, err := d.file.WriteString(str)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Files returns the paths of the retained log files, oldest first. Only the
+// files named by the dir are included, any other files are left alone.
+func (d *Dir) Files() ([]string, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+	var logs []logFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[2])
+		logs = append(logs, logFile{
+			path:  filepath.Join(d.path, entry.Name()),
+			base:  match[1],
+			index: index,
+		})
+	}
+	// names are timestamps, so they sort chronologically
+	sort.Slice(logs, func(i, j int) bool {
+		if logs[i].base != logs[j].base {
+			return logs[i].base < logs[j].base
+		}
+		return logs[i].index < logs[j].index
+	})
+	files := make([]string, len(logs))
+	for i, log := range logs {
+		files[i] = log.path
+	}
+	return files, nil
+}
+
+func (d *Dir) create(start time.Time) (*os.File, error) {
+	base := start.Format(timeFormat)
+	for i := d.nextIndex(base); ; i++ {
+		name := base + fileExt
+		if i > 0 {
+			// runs started within the same millisecond, suffixed so that
+			// they follow the base name
+			name = fmt.Sprintf("%s_%d%s", base, i, fileExt)
+		}
+		file, err := os.OpenFile(filepath.Join(d.path, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if !os.IsExist(err) {
+			return file, err
+		}
+	}
+}
+
+// nextIndex returns the index following that of any log files with the
+// provided base name, so that a new file is ordered after them even if the
+// earlier ones have been pruned.
+func (d *Dir) nextIndex(base string) int {
+	matches, _ := filepath.Glob(filepath.Join(d.path, base+"*"+fileExt))
+	next := 0
+	for _, match := range matches {
+		m := fileName.FindStringSubmatch(filepath.Base(match))
+		if m == nil || m[1] != base {
+			continue
+		}
+		index, _ := strconv.Atoi(m[2])
+		if index+1 > next {
+			next = index + 1
+		}
+	}
+	return next
+}
+
+func (d *Dir) link(name string) error {
+	// replace the link atomically so it always points at a log file
+	tmp := filepath.Join(d.path, LatestName+".tmp")
+	os.Remove(tmp)
+	err := os.Symlink(name, tmp)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(d.path, LatestName))
+}
+
+func (d *Dir) prune() error {
+	files, err := d.Files()
+	if err != nil {
+		return err
+	}
+	sizes := make([]int64, len(files))
+	var total int64
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		sizes[i] = info.Size()
+		total += sizes[i]
+	}
+	count := len(files)
+	for i, file := range files {
+		if i == len(files)-1 {
+			// never remove the latest log file
+			break
+		}
+		overCount := d.maxFiles > 0 && count > d.maxFiles
+		overSize := d.maxBytes > 0 && total > d.maxBytes
+		if !overCount && !overSize {
+			break
+		}
+		err := os.Remove(file)
+		if err != nil {
+			return err
+		}
+		count--
+		total -= sizes[i]
+	}
+	return nil
+}
+
+func (d *Dir) close() error {
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
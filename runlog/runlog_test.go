@@ -0,0 +1,120 @@
+package runlog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStartWritesLogAndLinksLatest(t *testing.T) {
+	d, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open log dir: %v", err)
+	}
+	d.StripANSI(true)
+
+	path, err := d.Start(time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatalf("failed to start log: %v", err)
+	}
+	if _, err := d.Write([]byte("\x1b[31mFAIL\x1b[0m\n")); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+	if err := d.Stop(); err != nil {
+		t.Fatalf("failed to stop log: %v", err)
+	}
+
+	contents, err := os.ReadFile(filepath.Join(d.path, LatestName))
+	if err != nil {
+		t.Fatalf("failed to read latest log: %v", err)
+	}
+	if string(contents) != "FAIL\n" {
+		t.Fatalf("latest log = %q, want %q", contents, "FAIL\n")
+	}
+	target, err := os.Readlink(filepath.Join(d.path, LatestName))
+	if err != nil {
+		t.Fatalf("failed to read latest link: %v", err)
+	}
+	if target != filepath.Base(path) {
+		t.Fatalf("latest link = %q, want %q", target, filepath.Base(path))
+	}
+}
+
+func TestRetentionLimits(t *testing.T) {
+	d, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open log dir: %v", err)
+	}
+	d.MaxFiles(2)
+
+	start := time.Unix(1700000000, 0)
+	var paths []string
+	for i := 0; i < 4; i++ {
+		path, err := d.Start(start.Add(time.Duration(i) * time.Second))
+		if err != nil {
+			t.Fatalf("failed to start log: %v", err)
+		}
+		d.Write([]byte("output\n"))
+		paths = append(paths, path)
+	}
+	d.Stop()
+
+	files, err := d.Files()
+	if err != nil {
+		t.Fatalf("failed to list logs: %v", err)
+	}
+	if len(files) != 2 || files[0] != paths[2] || files[1] != paths[3] {
+		t.Fatalf("files = %v, want %v", files, paths[2:])
+	}
+
+	// the latest log is retained even if it exceeds the size limit
+	d.MaxFiles(0)
+	d.MaxBytes(1)
+	if err := d.Stop(); err != nil {
+		t.Fatalf("failed to stop log: %v", err)
+	}
+	files, err = d.Files()
+	if err != nil {
+		t.Fatalf("failed to list logs: %v", err)
+	}
+	if len(files) != 1 || files[0] != paths[3] {
+		t.Fatalf("files = %v, want %v", files, paths[3:])
+	}
+}
+
+func TestRetentionIgnoresOtherFiles(t *testing.T) {
+	d, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open log dir: %v", err)
+	}
+	d.MaxFiles(2)
+
+	own := filepath.Join(d.path, "server.log")
+	if err := os.WriteFile(own, []byte("keep\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	// runs started within the same millisecond
+	start := time.Unix(1700000000, 0)
+	var paths []string
+	for i := 0; i < 12; i++ {
+		path, err := d.Start(start)
+		if err != nil {
+			t.Fatalf("failed to start log: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	d.Stop()
+
+	files, err := d.Files()
+	if err != nil {
+		t.Fatalf("failed to list logs: %v", err)
+	}
+	if len(files) != 2 || files[0] != paths[10] || files[1] != paths[11] {
+		t.Fatalf("files = %v, want %v", files, paths[10:])
+	}
+	if _, err := os.Stat(own); err != nil {
+		t.Fatalf("unrelated log file was removed: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	// the host is ignored when dialing a unix socket
+	socketURL = "http://witch"
+	// DefaultMaxTokenSize is the default max size of a line of output.
+	DefaultMaxTokenSize = 1024 * 1000 * 2
+	// each byte of a line may be escaped as up to 6 bytes of JSON, and the
+	// event has a few more fields
+	eventOverhead = 4096
+	escapeFactor  = 6
+)
+
+// Client represents a client for a server listening on a unix socket.
+type Client struct {
+	http         *http.Client
+	maxTokenSize int
+}
+
+// NewClient instantiates and returns a new client for the provided unix
+// socket.
+func NewClient(socket string) *Client {
+	return &Client{
+		maxTokenSize: DefaultMaxTokenSize,
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+func decodeResponse(res *http.Response, v interface{}) error {
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&body)
+		return fmt.Errorf("request failed with status %d: %s", res.StatusCode, body.Error)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// Status returns the current status of the watch.
+func (c *Client) Status() (Status, error) {
+	var status Status
+	res, err := c.http.Get(socketURL + "/status")
+	if err != nil {
+		return status, err
+	}
+	err = decodeResponse(res, &status)
+	return status, err
+}
+
+// Do requests the provided action, one of `run`, `pause`, `resume` or `stop`.
+// The action is applied asynchronously by the watch.
+func (c *Client) Do(action string) error {
+	var accepted struct {
+		Action string `json:"action"`
+	}
+	res, err := c.http.Post(socketURL+"/"+action, "application/json", nil)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(res, &accepted)
+}
+
+// MaxTokenSize sets the max size of a line of output received by Stream,
+// which should match that of the server.
+func (c *Client) MaxTokenSize(numBytes int) {
+	c.maxTokenSize = numBytes
+}
+
+// Stream invokes the provided function for each event received until the
+// stream is closed.
+func (c *Client) Stream(fn func(Event)) error {
+	res, err := c.http.Get(socketURL + "/events")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), c.maxTokenSize*escapeFactor+eventOverhead)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var event Event
+		err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event)
+		if err != nil {
+			return err
+		}
+		fn(event)
+	}
+	err = scanner.Err()
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		// the server exited
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,316 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kbirk/witch/problem"
+)
+
+const (
+	unixPrefix       = "unix:"
+	subscriberBuffer = 256
+)
+
+// Status represents the current status of the watch.
+type Status struct {
+	Running        bool              `json:"running"`
+	State          string            `json:"state,omitempty"`
+	Paused         bool              `json:"paused"`
+	Cmd            string            `json:"cmd,omitempty"`
+	Pid            int               `json:"pid,omitempty"`
+	StartedAt      *time.Time        `json:"started_at,omitempty"`
+	LastExitCode   *int              `json:"last_exit_code,omitempty"`
+	LastOutcome    string            `json:"last_outcome,omitempty"`
+	LastDurationMs int64             `json:"last_duration_ms"`
+	WatchedFiles   uint64            `json:"watched_files"`
+	LastProblems   []problem.Problem `json:"last_problems,omitempty"`
+}
+
+// Event represents a single event broadcast to stream subscribers.
+type Event struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Path       string    `json:"path,omitempty"`
+	Event      string    `json:"event,omitempty"`
+	Cmd        string    `json:"cmd,omitempty"`
+	Pid        int       `json:"pid,omitempty"`
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	Outcome    string    `json:"outcome,omitempty"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	Files      []string  `json:"files,omitempty"`
+	Line       string    `json:"line,omitempty"`
+}
+
+// Controller represents the watch being controlled by the server.
+type Controller interface {
+	Status() Status
+	Run()
+	Pause()
+	Resume()
+	Files() ([]string, error)
+	Stop()
+}
+
+// Server represents an HTTP server exposing the status and controls of the
+// watch.
+type Server struct {
+	controller  Controller
+	listeners   []net.Listener
+	sockets     []string
+	mu          *sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// New instantiates and returns a new server struct.
+func New(controller Controller) *Server {
+	return &Server{
+		controller:  controller,
+		mu:          &sync.Mutex{},
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Listen starts serving on the provided address. Addresses prefixed with
+// `unix:` are served over a unix socket. Listen may be called multiple times
+// to serve on multiple addresses.
+func (s *Server) Listen(addr string) error {
+	var listener net.Listener
+	var err error
+	if strings.HasPrefix(addr, unixPrefix) {
+		socket := strings.TrimPrefix(addr, unixPrefix)
+		if SocketInUse(socket) {
+			return fmt.Errorf("socket %s is in use by another process", socket)
+		}
+		// remove any stale socket
+		os.Remove(socket)
+		listener, err = net.Listen("unix", socket)
+		if err == nil {
+			s.sockets = append(s.sockets, socket)
+		}
+	} else {
+		listener, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	s.listeners = append(s.listeners, listener)
+	go http.Serve(listener, s.Handler())
+	return nil
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	var err error
+	for _, listener := range s.listeners {
+		e := listener.Close()
+		if e != nil {
+			err = e
+		}
+	}
+	for _, socket := range s.sockets {
+		os.Remove(socket)
+	}
+	return err
+}
+
+// SocketInUse returns whether or not the provided unix socket is accepting
+// connections.
+func SocketInUse(socket string) bool {
+	conn, err := net.DialTimeout("unix", socket, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Handler returns the HTTP handler for the server endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/run", s.handleAction("run", s.controller.Run))
+	mux.HandleFunc("/pause", s.handleAction("pause", s.controller.Pause))
+	mux.HandleFunc("/resume", s.handleAction("resume", s.controller.Resume))
+	mux.HandleFunc("/stop", s.handleStop)
+	mux.HandleFunc("/files", s.handleFiles)
+	mux.HandleFunc("/events", s.handleEvents)
+	return mux
+}
+
+// Broadcast sends the provided event to all stream subscribers.
+func (s *Server) Broadcast(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subscribers {
+		select {
+		case sub <- event:
+		default:
+			// drop events for subscribers that are not keeping up
+		}
+	}
+}
+
+func (s *Server) subscribe() chan Event {
+	sub := make(chan Event, subscriberBuffer)
+	s.mu.Lock()
+	s.subscribers[sub] = struct{}{}
+	s.mu.Unlock()
+	return sub
+}
+
+func (s *Server) unsubscribe(sub chan Event) {
+	s.mu.Lock()
+	delete(s.subscribers, sub)
+	s.mu.Unlock()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{
+		"error": err.Error(),
+	})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, req *http.Request) {
+	if !checkGet(w, req) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.controller.Status())
+}
+
+// writeAccepted responds that the action was accepted. Actions are applied
+// asynchronously by the watch, so the status is not included as it may not
+// reflect the action yet.
+func writeAccepted(w http.ResponseWriter, action string) {
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"action": action,
+	})
+}
+
+// checkOrigin returns whether the request was sent from the same origin,
+// writing an error response if not. Browsers set the Origin header on
+// cross-origin fetches and event streams, while other clients omit it.
+func checkOrigin(w http.ResponseWriter, req *http.Request) bool {
+	if origin := req.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != req.Host {
+			writeError(w, http.StatusForbidden, fmt.Errorf("origin %s not allowed", origin))
+			return false
+		}
+	}
+	return true
+}
+
+// checkGet returns whether the request is a GET which is not from a foreign
+// origin, writing an error response if not.
+func checkGet(w http.ResponseWriter, req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return false
+	}
+	return checkOrigin(w, req)
+}
+
+// checkPost returns whether the request is a POST which could not have been
+// sent cross-origin by a browser, writing an error response if not. Requiring
+// a JSON content type forces browsers to preflight the request, which is not
+// answered, and requests from a foreign origin are rejected outright.
+func checkPost(w http.ResponseWriter, req *http.Request) bool {
+	if req.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return false
+	}
+	if !checkOrigin(w, req) {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("content type must be application/json"))
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleAction(name string, action func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !checkPost(w, req) {
+			return
+		}
+		action()
+		writeAccepted(w, name)
+	}
+}
+
+func (s *Server) handleStop(w http.ResponseWriter, req *http.Request) {
+	if !checkPost(w, req) {
+		return
+	}
+	// respond before stopping, as the process is about to exit
+	writeAccepted(w, "stop")
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	s.controller.Stop()
+}
+
+func (s *Server) handleFiles(w http.ResponseWriter, req *http.Request) {
+	if !checkGet(w, req) {
+		return
+	}
+	files, err := s.controller.Files()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, files)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, req *http.Request) {
+	if !checkGet(w, req) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	sub := s.subscribe()
+	defer s.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case event := <-sub:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeController struct {
+	status  Status
+	runs    int
+	stopped bool
+	files   []string
+}
+
+func (c *fakeController) Status() Status {
+	return c.status
+}
+
+func (c *fakeController) Run() {
+	c.runs++
+}
+
+func (c *fakeController) Pause() {
+	c.status.Paused = true
+}
+
+func (c *fakeController) Resume() {
+	c.status.Paused = false
+}
+
+func (c *fakeController) Stop() {
+	c.stopped = true
+}
+
+func (c *fakeController) Files() ([]string, error) {
+	return c.files, nil
+}
+
+func TestStatusAndActions(t *testing.T) {
+	controller := &fakeController{
+		status: Status{
+			WatchedFiles: 2,
+		},
+		files: []string{"main.go", "README.md"},
+	}
+	ts := httptest.NewServer(New(controller).Handler())
+	defer ts.Close()
+
+	var status Status
+	getJSON(t, ts.URL+"/status", &status)
+	if status.WatchedFiles != 2 {
+		t.Fatalf("status.WatchedFiles = %d, want 2", status.WatchedFiles)
+	}
+
+	res, err := http.Get(ts.URL + "/run")
+	if err != nil {
+		t.Fatalf("GET /run failed: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET /run status = %d, want %d", res.StatusCode, http.StatusMethodNotAllowed)
+	}
+
+	var accepted map[string]string
+	postJSON(t, ts.URL+"/run", &accepted)
+	if controller.runs != 1 {
+		t.Fatalf("runs = %d, want 1", controller.runs)
+	}
+	if accepted["action"] != "run" {
+		t.Fatalf("accepted action = %q, want %q", accepted["action"], "run")
+	}
+	postJSON(t, ts.URL+"/pause", &accepted)
+	getJSON(t, ts.URL+"/status", &status)
+	if !status.Paused {
+		t.Fatal("status.Paused = false after pause, want true")
+	}
+	postJSON(t, ts.URL+"/resume", &accepted)
+	getJSON(t, ts.URL+"/status", &status)
+	if status.Paused {
+		t.Fatal("status.Paused = true after resume, want false")
+	}
+
+	postJSON(t, ts.URL+"/stop", &accepted)
+	if !controller.stopped {
+		t.Fatal("stopped = false after stop, want true")
+	}
+
+	var files []string
+	getJSON(t, ts.URL+"/files", &files)
+	if !reflect.DeepEqual(files, controller.files) {
+		t.Fatalf("files = %#v, want %#v", files, controller.files)
+	}
+}
+
+func TestActionsRejectCrossOrigin(t *testing.T) {
+	controller := &fakeController{}
+	ts := httptest.NewServer(New(controller).Handler())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		origin      string
+		want        int
+	}{
+		{"form", "text/plain", "", http.StatusUnsupportedMediaType},
+		{"missing content type", "", "", http.StatusUnsupportedMediaType},
+		{"foreign origin", "application/json", "http://evil.example", http.StatusForbidden},
+		{"same origin", "application/json; charset=utf-8", ts.URL, http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/run", nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("POST /run failed: %v", err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.want {
+				t.Fatalf("POST /run status = %d, want %d", res.StatusCode, tt.want)
+			}
+		})
+	}
+	if controller.runs != 1 {
+		t.Fatalf("runs = %d, want 1", controller.runs)
+	}
+}
+
+func TestReadsRejectCrossOrigin(t *testing.T) {
+	controller := &fakeController{}
+	ts := httptest.NewServer(New(controller).Handler())
+	defer ts.Close()
+
+	for _, path := range []string{"/status", "/files", "/events"} {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		req.Header.Set("Origin", "http://evil.example")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusForbidden {
+			t.Fatalf("GET %s status = %d, want %d", path, res.StatusCode, http.StatusForbidden)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/status", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Origin", ts.URL)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /status failed: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET /status status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+func TestEventsStream(t *testing.T) {
+	s := New(&fakeController{})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatalf("GET /events failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	// wait for the subscription to be registered
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		n := len(s.subscribers)
+		s.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stream subscriber was never registered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	s.Broadcast(Event{
+		Type:  "file",
+		Path:  "main.go",
+		Event: "changed",
+	})
+
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read event: %v", err)
+	}
+	if line != "event: file\n" {
+		t.Fatalf("event line = %q, want %q", line, "event: file\n")
+	}
+	line, err = reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read event data: %v", err)
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+		t.Fatalf("failed to decode event data %q: %v", line, err)
+	}
+	if event.Path != "main.go" || event.Event != "changed" {
+		t.Fatalf("event = %#v, want main.go changed", event)
+	}
+}
+
+func TestClientStreamsLongLines(t *testing.T) {
+	s := New(&fakeController{})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	// end the stream so that the server can close
+	defer ts.CloseClientConnections()
+
+	client := &Client{
+		maxTokenSize: DefaultMaxTokenSize,
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "tcp", ts.Listener.Addr().String())
+				},
+			},
+		},
+	}
+
+	line := strings.Repeat("\x1b", 128*1024) + "\n"
+	received := make(chan Event, 1)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- client.Stream(func(event Event) {
+			received <- event
+		})
+	}()
+
+	// wait for the subscription to be registered
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		n := len(s.subscribers)
+		s.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stream subscriber was never registered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.Broadcast(Event{
+		Type: "output",
+		Line: line,
+	})
+
+	select {
+	case event := <-received:
+		if event.Line != line {
+			t.Fatalf("received a line of %d bytes, want %d", len(event.Line), len(line))
+		}
+	case err := <-errs:
+		t.Fatalf("stream failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("long line was never received")
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "witch.sock")
+
+	// a socket left behind by a process that did not exit cleanly
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", socket, err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	if SocketInUse(socket) {
+		t.Fatal("SocketInUse() = true for a stale socket, want false")
+	}
+
+	s := New(&fakeController{})
+	if err := s.Listen("unix:" + socket); err != nil {
+		t.Fatalf("failed to listen on stale socket: %v", err)
+	}
+	defer s.Close()
+	if !SocketInUse(socket) {
+		t.Fatal("SocketInUse() = false after listening, want true")
+	}
+
+	// a socket in use is not replaced
+	if err := New(&fakeController{}).Listen("unix:" + socket); err == nil {
+		t.Fatal("listening on a socket in use succeeded, want error")
+	}
+}
+
+func getJSON(t *testing.T, url string, v interface{}) {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer res.Body.Close()
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Fatalf("failed to decode response from %s: %v", url, err)
+	}
+}
+
+func postJSON(t *testing.T, url string, v interface{}) {
+	t.Helper()
+	res, err := http.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", url, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST %s status = %d, want %d", url, res.StatusCode, http.StatusAccepted)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Fatalf("failed to decode response from %s: %v", url, err)
+	}
+}
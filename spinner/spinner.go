@@ -3,6 +3,9 @@ package spinner
 import (
 	"fmt"
 	"math/rand"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/fatih/color"
 
@@ -42,27 +45,60 @@ var (
 	// MagicLength is the number of chars in the magic string
 	MagicLength = len(frames[len(frames)-1])
 	colorHash   = magicHash([]string{"`", "°", "º", "¤", "ø", ",", "¸"})
+	frameWidth  = maxFrameWidth(frames)
 )
 
 // Spinner represents a spinning console output.
 type Spinner struct {
-	c int
-	w *writer.PrettyWriter
+	c      int
+	w      *writer.PrettyWriter
+	paused bool
+	label  string
+	mu     *sync.Mutex
 }
 
 // New instantiates and returns a new spinner struct.
 func New(writer *writer.PrettyWriter) *Spinner {
 	return &Spinner{
-		w: writer,
+		w:  writer,
+		mu: &sync.Mutex{},
 	}
 }
 
+// Pause sets whether or not the spinner displays the paused state.
+func (s *Spinner) Pause(paused bool) {
+	s.paused = paused
+}
+
+// Label sets a label displayed beside the spinner.
+func (s *Spinner) Label(label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.label = label
+}
+
 // Tick increments the cursor.
 func (s *Spinner) Tick(count uint64) {
+	s.mu.Lock()
+	label := s.label
+	s.mu.Unlock()
+	if s.paused {
+		paused := fmt.Sprintf("%swatching %s",
+			cursor.Hide,
+			color.YellowString("paused"))
+		s.w.WriteAndFlagToReplace([]byte(paused))
+		return
+	}
 	s.c = (s.c + 1) % len(frames)
 	magic := fmt.Sprintf("%s%s",
 		cursor.Hide,
 		castMagic(frames[s.c]))
+	if label != "" {
+		magic = fmt.Sprintf("%s%s %s",
+			magic,
+			strings.Repeat(" ", frameWidth-utf8.RuneCountInString(frames[s.c])),
+			label)
+	}
 	s.w.WriteAndFlagToReplace([]byte(magic))
 }
 
@@ -74,6 +110,16 @@ func (s *Spinner) Done() {
 	s.w.WriteStringf(goodbye)
 }
 
+func maxFrameWidth(frames []string) int {
+	width := 0
+	for _, frame := range frames {
+		if n := utf8.RuneCountInString(frame); n > width {
+			width = n
+		}
+	}
+	return width
+}
+
 func magicHash(strs []string) map[string][]string {
 	colors := make(map[string][]string)
 	for _, str := range strs {
@@ -0,0 +1,62 @@
+package tty
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// IsTerminal returns whether or not the provided file descriptor refers to a
+// terminal.
+func IsTerminal(fd int) bool {
+	_, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	return err == nil
+}
+
+// State represents the state of a terminal.
+type State struct {
+	termios unix.Termios
+}
+
+// MakeRaw puts the terminal referred to by the provided file descriptor into
+// raw mode, returning the previous state so that it may be restored. Signal
+// generating keys such as Ctrl-C remain enabled and output processing is left
+// untouched so that regular output is unaffected.
+func MakeRaw(fd int) (*State, error) {
+	return makeRaw(fd, false)
+}
+
+// MakeRawNoSignals puts the terminal into raw mode like MakeRaw, but also
+// disables signal generating keys so that Ctrl-C, Ctrl-Z and Ctrl-\ are read
+// as input instead.
+func MakeRawNoSignals(fd int) (*State, error) {
+	return makeRaw(fd, true)
+}
+
+func makeRaw(fd int, noSignals bool) (*State, error) {
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+	state := &State{
+		termios: *termios,
+	}
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.IEXTEN
+	if noSignals {
+		termios.Lflag &^= unix.ISIG
+	}
+	termios.Cflag &^= unix.CSIZE | unix.PARENB
+	termios.Cflag |= unix.CS8
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	err = unix.IoctlSetTermios(fd, ioctlSetTermios, termios)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Restore restores the terminal referred to by the provided file descriptor to
+// the provided state.
+func Restore(fd int, state *State) error {
+	return unix.IoctlSetTermios(fd, ioctlSetTermios, &state.termios)
+}
@@ -3,12 +3,14 @@ package watcher
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/pkg/errors"
 
 	"github.com/kbirk/witch/glob"
 )
 
+// EventType represents the type of a detected file event.
 type EventType int
 
 const (
@@ -20,12 +22,25 @@ const (
 	Removed
 )
 
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	}
+	return "changed"
+}
+
 // Watcher represents a simple struct for scanning and checking for any changes
-// that occur in a set of watched files and directories.
+// that occur in a set of watched files and directories. It is safe for
+// concurrent use.
 type Watcher struct {
 	watches []string
 	ignores []string
 	prev    map[string]os.FileInfo
+	mu      *sync.Mutex
 }
 
 // Event represents a single detected file event.
@@ -36,21 +51,32 @@ type Event struct {
 
 // New instantiates and returns a new watcher struct.
 func New() *Watcher {
-	return &Watcher{}
+	return &Watcher{
+		mu: &sync.Mutex{},
+	}
 }
 
 // Watch adds a single file, directory, or glob to the file watch list.
 func (w *Watcher) Watch(arg string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	w.watches = append(w.watches, arg)
 }
 
 // Ignore adds a single file, directory, or glob to the file ignore list.
 func (w *Watcher) Ignore(arg string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	w.ignores = append(w.ignores, arg)
 }
 
 // ScanForEvents returns any events that occurred since the last scan.
 func (w *Watcher) ScanForEvents() ([]Event, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	// get all current watches
 	targets, err := w.scan()
 	if err != nil {
@@ -62,6 +88,9 @@ func (w *Watcher) ScanForEvents() ([]Event, error) {
 
 // NumTargets returns the number of currently watched targets.
 func (w *Watcher) NumTargets() (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	// get all current watches
 	targets, err := w.scan()
 	if err != nil {
@@ -71,6 +100,23 @@ func (w *Watcher) NumTargets() (uint64, error) {
 	return uint64(len(targets)), nil
 }
 
+// Targets returns the paths of all currently watched targets.
+func (w *Watcher) Targets() ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// get all current watches
+	targets, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(targets))
+	for path := range targets {
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
 func (w *Watcher) scanIgnores(args []string) ([]string, error) {
 	ignores := make([]string, 0, len(args))
 	for _, arg := range args {
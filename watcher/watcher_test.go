@@ -80,6 +80,50 @@ func TestWatcherGlobFiltersTargets(t *testing.T) {
 	assertEvents(t, scanEvents(t, w), Event{Type: Added, Path: goPath})
 }
 
+func TestWatcherTargets(t *testing.T) {
+	root := t.TempDir()
+	goPath := filepath.Join(root, "main.go")
+	markdownPath := filepath.Join(root, "README.md")
+	writeFileAt(t, goPath, "package main\n", time.Unix(1700000000, 0))
+	writeFileAt(t, markdownPath, "# docs", time.Unix(1700000000, 0))
+
+	w := New()
+	w.Watch(filepath.Join(root, "*.go"))
+
+	targets, err := w.Targets()
+	if err != nil {
+		t.Fatalf("listing targets failed: %v", err)
+	}
+	if len(targets) != 1 || targets[0] != goPath {
+		t.Fatalf("Targets() = %#v, want %#v", targets, []string{goPath})
+	}
+}
+
+func TestWatcherTargetsDuringScan(t *testing.T) {
+	root := t.TempDir()
+	writeFileAt(t, filepath.Join(root, "a.txt"), "a", time.Unix(1700000000, 0))
+
+	w := New()
+	w.Watch(root)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			if _, err := w.Targets(); err != nil {
+				t.Errorf("targets failed: %v", err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if _, err := w.ScanForEvents(); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+	}
+	<-done
+}
+
 func scanEvents(t *testing.T, w *Watcher) []Event {
 	t.Helper()
 	events, err := w.ScanForEvents()
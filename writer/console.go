@@ -0,0 +1,104 @@
+package writer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/kbirk/witch/cursor"
+)
+
+// Console represents a single output destination. It owns the state of
+// whether the previously written line should be overwritten, and whether a
+// partial line has been written, so writers that share a console cooperate,
+// and writers to different consoles do not interfere.
+type Console struct {
+	out       io.Writer
+	overwrite bool
+	midLine   bool
+	mu        *sync.Mutex
+}
+
+// NewConsole instantiates and returns a new console writing to the provided
+// writer.
+func NewConsole(out io.Writer) *Console {
+	return &Console{
+		out: out,
+		mu:  &sync.Mutex{},
+	}
+}
+
+// Write implements the standard Write interface. The written output is kept.
+func (c *Console) Write(p []byte) (int, error) {
+	c.WriteLine(string(p))
+	return len(p), nil
+}
+
+// WriteLine writes the provided string, overwriting the previous line if it
+// was flagged to be replaced.
+func (c *Console) WriteLine(str string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.endLine()
+	c.writeString(str)
+	c.overwrite = false
+}
+
+// WritePartial writes the provided string as is, it may end part way through
+// a line or redraw the current line with carriage returns. Replaceable lines
+// are not written until the partial line is ended.
+func (c *Console) WritePartial(str string) {
+	if str == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeString(str)
+	c.overwrite = false
+	c.midLine = !strings.HasSuffix(str, "\n")
+}
+
+// EndLine terminates any partially written line.
+func (c *Console) EndLine() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.endLine()
+}
+
+// WriteLineToReplace writes the provided string, flagging it to be
+// overwritten by the next write.
+func (c *Console) WriteLineToReplace(str string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.midLine {
+		// don't clobber a partial line, such as a prompt or progress bar
+		return
+	}
+
+	c.writeString(str)
+	c.overwrite = true // set that we should replace this line on the next write
+}
+
+func (c *Console) endLine() {
+	if c.midLine {
+		fmt.Fprint(c.out, "\n")
+		c.midLine = false
+	}
+}
+
+func (c *Console) writeString(str string) {
+	if c.overwrite {
+		// In case the witch process is wrapped by some parent process with a log prefix (ex. docker compose)
+		// we want to maintain any existing log prefix and only overwrite the rest of the line.
+		// So we move up, clear and then write the new line.
+		fmt.Fprintf(c.out, "%s\r%s", cursor.ClearLine, str)
+	} else {
+		fmt.Fprintf(c.out, "%s", str)
+	}
+}
@@ -0,0 +1,47 @@
+package writer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kbirk/witch/cursor"
+)
+
+func TestConsoleOverwritesReplaceableLine(t *testing.T) {
+	output := &bytes.Buffer{}
+	console := NewConsole(output)
+
+	console.WriteLineToReplace("spinning")
+	console.WriteLine("done\n")
+	console.WriteLine("kept\n")
+
+	want := "spinning" + cursor.ClearLine + "\rdone\nkept\n"
+	if output.String() != want {
+		t.Fatalf("console output = %q, want %q", output.String(), want)
+	}
+}
+
+func TestConsolesDoNotShareOverwriteState(t *testing.T) {
+	first := &bytes.Buffer{}
+	second := &bytes.Buffer{}
+
+	NewConsole(first).WriteLineToReplace("spinning")
+	NewConsole(second).WriteLine("line\n")
+
+	if second.String() != "line\n" {
+		t.Fatalf("second console output = %q, want %q", second.String(), "line\n")
+	}
+}
+
+func TestConsolePreservesPartialLines(t *testing.T) {
+	output := &bytes.Buffer{}
+	console := NewConsole(output)
+
+	console.WritePartial("password: ")
+	console.WriteLineToReplace("spinning")
+	console.WriteLine("done\n")
+
+	if got, want := output.String(), "password: \ndone\n"; got != want {
+		t.Fatalf("console output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,61 @@
+package writer
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Format represents the format of the written output.
+type Format int
+
+const (
+	// TextFormat writes human readable, colored lines.
+	TextFormat Format = iota
+	// JSONFormat writes one JSON object per line.
+	JSONFormat
+)
+
+// ParseFormat returns the format for the provided name.
+func ParseFormat(name string) (Format, bool) {
+	switch name {
+	case "text":
+		return TextFormat, true
+	case "json":
+		return JSONFormat, true
+	}
+	return TextFormat, false
+}
+
+// Entry represents a single structured log entry.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Message    string    `json:"message,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Event      string    `json:"event,omitempty"`
+	Cmd        string    `json:"cmd,omitempty"`
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	Task       string    `json:"task,omitempty"`
+	Stream     string    `json:"stream,omitempty"`
+	Severity   string    `json:"severity,omitempty"`
+	LineNumber int       `json:"line_number,omitempty"`
+	Col        int       `json:"col,omitempty"`
+	Line       *string   `json:"line,omitempty"`
+}
+
+func entryMessage(str string) string {
+	return strings.TrimSpace(StripANSI(str))
+}
+
+func writeEntry(console *Console, entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	console.WriteLine(string(data) + "\n")
+}
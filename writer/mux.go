@@ -0,0 +1,132 @@
+package writer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+var (
+	taskColors = []color.Attribute{
+		color.FgCyan,
+		color.FgYellow,
+		color.FgBlue,
+		color.FgMagenta,
+		color.FgGreen,
+		color.FgHiCyan,
+		color.FgHiYellow,
+		color.FgHiBlue,
+		color.FgHiMagenta,
+		color.FgHiGreen,
+	}
+)
+
+// Mux represents a multiplexer for the output of concurrent tasks. Each task
+// is written with a colored name prefix, aligned to the widest task name.
+// Only the tasks with open writers are tracked, so colors and alignment are
+// determined by the batch of tasks running together.
+type Mux struct {
+	console *Console
+	tasks   map[string]*muxTask
+	width   int
+	mu      *sync.Mutex
+}
+
+// muxTask represents a task with open writers.
+type muxTask struct {
+	color   *color.Color
+	index   int
+	writers int
+}
+
+// NewMux instantiates and returns a new multiplexer writing to the provided
+// console.
+func NewMux(console *Console) *Mux {
+	return &Mux{
+		console: console,
+		tasks:   make(map[string]*muxTask),
+		mu:      &sync.Mutex{},
+	}
+}
+
+// Writer returns a new cmd writer whose lines are prefixed with the provided
+// task name. The writer must be closed once the task completes.
+func (m *Mux) Writer(task string) *CmdWriter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tasks[task]
+	if !ok {
+		index := m.freeIndex()
+		t = &muxTask{
+			color: color.New(taskColors[index%len(taskColors)]),
+			index: index,
+		}
+		m.tasks[task] = t
+		m.updateWidth()
+	}
+	t.writers++
+
+	w := NewCmd(task, m.console)
+	w.mux = m
+	w.task = task
+	return w
+}
+
+// release stops tracking the task once all of its writers are closed.
+func (m *Mux) release(task string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tasks[task]
+	if !ok {
+		return
+	}
+	t.writers--
+	if t.writers > 0 {
+		return
+	}
+	delete(m.tasks, task)
+	m.updateWidth()
+}
+
+// freeIndex returns the lowest color index not used by an open task.
+func (m *Mux) freeIndex() int {
+	used := make(map[int]bool, len(m.tasks))
+	for _, t := range m.tasks {
+		used[t.index] = true
+	}
+	index := 0
+	for used[index] {
+		index++
+	}
+	return index
+}
+
+func (m *Mux) updateWidth() {
+	m.width = 0
+	for task := range m.tasks {
+		if len(task) > m.width {
+			m.width = len(task)
+		}
+	}
+}
+
+func (m *Mux) prefix(task string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := color.New(taskColors[0])
+	if t, ok := m.tasks[task]; ok {
+		c = t.color
+	}
+	padding := ""
+	if len(task) < m.width {
+		padding = strings.Repeat(" ", m.width-len(task))
+	}
+	return fmt.Sprintf("%s %s ",
+		c.Sprintf("%s%s", task, padding),
+		color.HiBlackString("|"))
+}
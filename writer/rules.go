@@ -0,0 +1,78 @@
+package writer
+
+import (
+	"regexp"
+
+	"github.com/fatih/color"
+)
+
+// highlight represents a pattern whose matching lines are colored.
+type highlight struct {
+	pattern *regexp.Regexp
+	color   *color.Color
+}
+
+// Rules represents the rules deciding which lines of cmd output are written,
+// and how they are colored. Lines are matched with any ANSI escape sequences
+// removed.
+type Rules struct {
+	excludes   []*regexp.Regexp
+	includes   []*regexp.Regexp
+	highlights []highlight
+}
+
+// Exclude drops any lines matching the provided pattern.
+func (r *Rules) Exclude(pattern *regexp.Regexp) {
+	r.excludes = append(r.excludes, pattern)
+}
+
+// Include only keeps lines matching the provided pattern, or any other
+// included pattern.
+func (r *Rules) Include(pattern *regexp.Regexp) {
+	r.includes = append(r.includes, pattern)
+}
+
+// Highlight colors any lines matching the provided pattern. The first
+// matching highlight is used.
+func (r *Rules) Highlight(pattern *regexp.Regexp, attr color.Attribute) {
+	r.highlights = append(r.highlights, highlight{
+		pattern: pattern,
+		color:   color.New(attr),
+	})
+}
+
+// Empty returns whether or not no rules have been added.
+func (r *Rules) Empty() bool {
+	return len(r.excludes) == 0 && len(r.includes) == 0 && len(r.highlights) == 0
+}
+
+// Keep returns whether or not the provided line is written.
+func (r *Rules) Keep(line string) bool {
+	text := StripANSI(line)
+	for _, pattern := range r.excludes {
+		if pattern.MatchString(text) {
+			return false
+		}
+	}
+	if len(r.includes) == 0 {
+		return true
+	}
+	for _, pattern := range r.includes {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the provided line, colored by the first matching highlight.
+// The line must not include the trailing newline.
+func (r *Rules) Apply(line string) string {
+	text := StripANSI(line)
+	for _, h := range r.highlights {
+		if h.pattern.MatchString(text) {
+			return h.color.Sprint(text)
+		}
+	}
+	return line
+}
@@ -0,0 +1,34 @@
+package writer
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestRulesFilterWrittenLines(t *testing.T) {
+	withNoColor(t)
+
+	output := &bytes.Buffer{}
+	sink := &bytes.Buffer{}
+
+	rules := &Rules{}
+	rules.Include(regexp.MustCompile(`FAIL|ok`))
+	rules.Exclude(regexp.MustCompile(`^ok\s+cached`))
+	rules.Highlight(regexp.MustCompile(`FAIL`), color.FgRed)
+
+	cmdWriter := NewCmd("witch", NewConsole(output))
+	cmdWriter.Rules(rules)
+	cmdWriter.AddSink(sink)
+	cmdWriter.Write([]byte("=== RUN TestA\n\x1b[31mFAIL\x1b[0m pkg/a\nok  \tpkg/b\nok  \tcached pkg/c\n"))
+
+	if got, want := output.String(), "FAIL pkg/a\nok  \tpkg/b\n"; got != want {
+		t.Fatalf("filtered output = %q, want %q", got, want)
+	}
+	// sinks receive every line
+	if got, want := sink.String(), "=== RUN TestA\n\x1b[31mFAIL\x1b[0m pkg/a\nok  \tpkg/b\nok  \tcached pkg/c\n"; got != want {
+		t.Fatalf("sink output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package writer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/kbirk/witch/cursor"
+)
+
+const (
+	separatorWidth = 60
+	maxListedFiles = 3
+)
+
+// RunSummary represents the exit information of a single run.
+type RunSummary struct {
+	Outcome  string
+	ExitCode int
+	Duration time.Duration
+	Files    []string
+}
+
+// Clear clears the screen and scrollback of the console.
+func (c *Console) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(c.out, "%s%s%s", cursor.MoveCursorHome, cursor.ClearScreen, cursor.ClearScrollback)
+	c.overwrite = false
+	c.midLine = false
+}
+
+// WriteSeparator writes a banner with the provided label separating the
+// output of each run. It is only written as text.
+func (w *PrettyWriter) WriteSeparator(label string) {
+	if w.format == JSONFormat {
+		return
+	}
+	title := fmt.Sprintf("── %s · %s ", label, time.Now().Format(time.Stamp))
+	width := len([]rune(title))
+	if width < separatorWidth {
+		title += strings.Repeat("─", separatorWidth-width)
+	}
+	w.console.WriteLine(color.HiBlackString("%s", title) + "\n")
+}
+
+// WriteSummary writes a footer summarizing the run. It is only written as
+// text.
+func (w *PrettyWriter) WriteSummary(s RunSummary) {
+	if w.format == JSONFormat {
+		return
+	}
+	w.console.WriteLine(prefixedLine(w.name, summaryString(s)+"\n"))
+}
+
+func summaryString(s RunSummary) string {
+	var mark string
+	switch s.Outcome {
+	case "success":
+		mark = color.GreenString("✔ %s", s.Outcome)
+	case "killed":
+		mark = color.YellowString("✘ %s", s.Outcome)
+	default:
+		mark = color.RedString("✘ %s", s.Outcome)
+	}
+	res := fmt.Sprintf("%s %s %s %s %s",
+		mark,
+		color.HiBlackString("in"),
+		color.BlueString(s.Duration.Round(time.Millisecond).String()),
+		color.HiBlackString("with exit code"),
+		exitCodeString(s.ExitCode))
+	if len(s.Files) > 0 {
+		res += fmt.Sprintf(" %s %s",
+			color.HiBlackString("after changes to"),
+			filesString(s.Files))
+	}
+	return res
+}
+
+func exitCodeString(code int) string {
+	if code == 0 {
+		return color.GreenString("%d", code)
+	}
+	return color.RedString("%d", code)
+}
+
+func filesString(files []string) string {
+	listed := files
+	if len(listed) > maxListedFiles {
+		listed = listed[:maxListedFiles]
+	}
+	res := color.BlueString(strings.Join(listed, ", "))
+	if len(files) > len(listed) {
+		res += color.HiBlackString(" and %d more", len(files)-len(listed))
+	}
+	return res
+}
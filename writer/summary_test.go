@@ -0,0 +1,36 @@
+package writer
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSummaryString(t *testing.T) {
+	withNoColor(t)
+
+	got := summaryString(RunSummary{
+		Outcome:  "failure",
+		ExitCode: 2,
+		Duration: 1234567 * time.Microsecond,
+		Files:    []string{"a.go", "b.go", "c.go", "d.go"},
+	})
+	want := "✘ failure in 1.235s with exit code 2 after changes to a.go, b.go, c.go and 1 more"
+	if got != want {
+		t.Fatalf("summaryString() = %q, want %q", got, want)
+	}
+}
+
+func TestConsoleClearResetsLineState(t *testing.T) {
+	output := &bytes.Buffer{}
+	console := NewConsole(output)
+
+	console.WritePartial("partial")
+	console.Clear()
+	output.Reset()
+	console.WriteLine("line\n")
+
+	if output.String() != "line\n" {
+		t.Fatalf("console output = %q, want %q", output.String(), "line\n")
+	}
+}
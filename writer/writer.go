@@ -2,15 +2,16 @@ package writer
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/fatih/color"
-
-	"github.com/kbirk/witch/cursor"
 )
 
 const (
@@ -21,30 +22,78 @@ const (
 )
 
 var (
-	mu                          = &sync.Mutex{}
-	shouldOverwritePreviousLine = false
+	ansiRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]|\x1b\][^\x07]*\x07`)
 )
 
+// StripANSI removes any ANSI escape sequences from the provided string.
+func StripANSI(str string) string {
+	return ansiRegex.ReplaceAllString(str, "")
+}
+
 // PrettyWriter represents a pretty formatteed writer
 type PrettyWriter struct {
-	file *os.File
-	name string
+	console *Console
+	name    string
+	format  Format
 }
 
 // NewPretty instantiates and returns a new pretty writer.
-func NewPretty(name string, file *os.File) *PrettyWriter {
+func NewPretty(name string, console *Console) *PrettyWriter {
 	return &PrettyWriter{
-		name: name,
-		file: file,
+		name:    name,
+		console: console,
 	}
 }
 
+// SetFormat sets the format of the written output.
+func (w *PrettyWriter) SetFormat(format Format) {
+	w.format = format
+}
+
 // Write implements the standard Write interface.
 func (w *PrettyWriter) Write(p []byte) (int, error) {
-	writeLineToKeepWithPrefix(w.name, w.file, string(p))
+	if w.format == JSONFormat {
+		msg := entryMessage(string(p))
+		if msg != "" {
+			writeEntry(w.console, Entry{
+				Type:    "log",
+				Message: msg,
+			})
+		}
+		return len(p), nil
+	}
+	w.console.WriteLine(prefixedLine(w.name, string(p)))
 	return len(p), nil
 }
 
+// WriteEntryf writes the provided formatted string to the underlying
+// interface, or the provided entry if writing JSON.
+func (w *PrettyWriter) WriteEntryf(entry Entry, format string, args ...interface{}) {
+	str := fmt.Sprintf(format, args...)
+	if w.format == JSONFormat {
+		if entry.Message == "" {
+			entry.Message = entryMessage(str)
+		}
+		writeEntry(w.console, entry)
+		return
+	}
+	w.console.WriteLine(prefixedLine(w.name, str))
+}
+
+// WriteEntry writes the provided entry to the underlying interface. Entries
+// are only written when writing JSON.
+func (w *PrettyWriter) WriteEntry(entry Entry) {
+	if w.format == JSONFormat {
+		writeEntry(w.console, entry)
+	}
+}
+
+// WriteErrorf writes the provided formatted error string to the underlying
+// interface.
+func (w *PrettyWriter) WriteErrorf(format string, args ...interface{}) {
+	w.WriteEntryf(Entry{Type: "error"}, format, args...)
+}
+
 // WriteStringf writes the provided formatted string to the underlying
 // interface.
 func (w *PrettyWriter) WriteStringf(format string, args ...interface{}) {
@@ -53,19 +102,12 @@ func (w *PrettyWriter) WriteStringf(format string, args ...interface{}) {
 
 // Write implements the standard Write interface.
 func (w *PrettyWriter) WriteAndFlagToReplace(p []byte) (int, error) {
-	writeLineToBeReplacedWithPrefix(w.name, w.file, string(p))
-	return len(p), nil
-}
-
-func writeString(file *os.File, str string) {
-	if shouldOverwritePreviousLine {
-		// In case the witch process is wrapped by some parent process with a log prefix (ex. docker compose)
-		// we want to maintain any existing log prefix and only overwrite the rest of the line.
-		// So we move up, clear and then write the new line.
-		fmt.Fprintf(file, "%s\r%s", cursor.ClearLine, str)
-	} else {
-		fmt.Fprintf(file, "%s", str)
+	if w.format == JSONFormat {
+		// transient lines are not logged
+		return len(p), nil
 	}
+	w.console.WriteLineToReplace(prefixedLine(w.name, string(p)))
+	return len(p), nil
 }
 
 func getLogPrefix(n string) string {
@@ -75,51 +117,38 @@ func getLogPrefix(n string) string {
 	return fmt.Sprintf("%s %s %s", stamp, name, wand)
 }
 
-func writeLineToKeepWithPrefix(name string, file *os.File, format string, args ...interface{}) {
-	msg := color.HiBlackString("%s", fmt.Sprintf(format, args...))
-	output := fmt.Sprintf("%s %s", getLogPrefix(name), msg)
-
-	mu.Lock()
-	defer mu.Unlock()
-
-	writeString(file, output)
-	shouldOverwritePreviousLine = false
-}
-
-func writeLineToBeReplacedWithPrefix(name string, file *os.File, format string, args ...interface{}) {
-	msg := color.HiBlackString("%s", fmt.Sprintf(format, args...))
-	output := fmt.Sprintf("%s %s", getLogPrefix(name), msg)
-
-	mu.Lock()
-	defer mu.Unlock()
-
-	writeString(file, output)
-	shouldOverwritePreviousLine = true // set that we should replace this line on the next write
-}
-
-func writeLineToKeepWithoutPrefix(file *os.File, output string) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	writeString(file, output)
-	shouldOverwritePreviousLine = false
+func prefixedLine(name string, str string) string {
+	msg := color.HiBlackString("%s", str)
+	return fmt.Sprintf("%s %s", getLogPrefix(name), msg)
 }
 
 // CmdWriter represents a writer to log an output from the executed cmd.
 type CmdWriter struct {
 	name         string
-	file         *os.File
+	console      *Console
 	maxTokenSize int
 	buffer       string
+	tintStderr   bool
+	passThrough  bool
+	grouped      bool
+	group        string
+	mux          *Mux
+	rules        *Rules
+	transform    func(line string) []string
+	task         string
+	stderrBuffer string
+	format       Format
+	sinks        []io.Writer
 	done         chan struct{}
+	closed       bool
 	mu           *sync.Mutex
 }
 
 // NewCmd instantiates and returns a new cmd writer.
-func NewCmd(name string, file *os.File) *CmdWriter {
+func NewCmd(name string, console *Console) *CmdWriter {
 	return &CmdWriter{
 		name:         name,
-		file:         file,
+		console:      console,
 		maxTokenSize: bufio.MaxScanTokenSize,
 		mu:           &sync.Mutex{},
 	}
@@ -130,8 +159,157 @@ func (w *CmdWriter) MaxTokenSize(numBytes int) {
 	w.maxTokenSize = numBytes
 }
 
-// Proxy will forward the output from the provided os.File through the writer.
-func (w *CmdWriter) Proxy(f *os.File) {
+// SetFormat sets the format of the written output.
+func (w *CmdWriter) SetFormat(format Format) {
+	w.format = format
+}
+
+// Group sets whether or not the output is held and written as a single block
+// once flushed, rather than streamed as each line is read.
+func (w *CmdWriter) Group(group bool) {
+	w.grouped = group
+}
+
+// Rules sets the rules filtering and highlighting the written lines. Sinks
+// receive every line regardless of the rules.
+func (w *CmdWriter) Rules(rules *Rules) {
+	w.rules = rules
+}
+
+// Transform sets a function rewriting each line of output, without its
+// trailing newline, into zero or more lines. Rules and sinks are applied to
+// the rewritten lines.
+func (w *CmdWriter) Transform(transform func(line string) []string) {
+	w.transform = transform
+}
+
+func (w *CmdWriter) writeLine(line string, stream string) {
+	if w.transform == nil {
+		w.emitLine(line, stream)
+		return
+	}
+	for _, out := range w.transform(strings.TrimSuffix(line, "\n")) {
+		w.emitLine(strings.TrimSuffix(out, "\n")+"\n", stream)
+	}
+}
+
+func (w *CmdWriter) emitLine(line string, stream string) {
+	defer w.writeToSinks(line)
+	if w.rules != nil {
+		if !w.rules.Keep(line) {
+			return
+		}
+		line = w.rules.Apply(strings.TrimSuffix(line, "\n")) + "\n"
+	}
+	if w.format == JSONFormat {
+		text := StripANSI(strings.TrimSuffix(line, "\n"))
+		writeEntry(w.console, Entry{
+			Type:   "output",
+			Task:   w.task,
+			Stream: stream,
+			Line:   &text,
+		})
+	} else {
+		output := line
+		if w.mux != nil {
+			output = w.mux.prefix(w.task) + line
+		}
+		if w.grouped {
+			w.group += output
+		} else {
+			w.console.WriteLine(output)
+		}
+	}
+}
+
+func (w *CmdWriter) flushGroup() {
+	if len(w.group) > 0 {
+		// the block is written at once so it is not interleaved
+		w.console.WriteLine(w.group)
+		w.group = ""
+	}
+}
+
+// PassThrough sets whether or not partial lines and carriage return updates,
+// such as progress bars and prompts, are forwarded as soon as they are read
+// rather than once the line ends. It does not apply to JSON output.
+func (w *CmdWriter) PassThrough(passThrough bool) {
+	w.passThrough = passThrough
+}
+
+func (w *CmdWriter) passingThrough() bool {
+	// prefixed, grouped, filtered and transformed output is only written in
+	// whole lines
+	return w.passThrough && w.format == TextFormat && w.mux == nil && !w.grouped && w.rules == nil && w.transform == nil
+}
+
+// forward writes the output immediately, while buffering it into lines for
+// the sinks.
+func (w *CmdWriter) forward(output string, buffer *string) {
+	w.console.WritePartial(output)
+	*buffer += output
+	for {
+		index := strings.IndexByte(*buffer, '\n')
+		if index == -1 {
+			// no endline
+			break
+		}
+		w.writeToSinks(collapseCarriageReturns((*buffer)[0:index]) + "\n")
+		*buffer = (*buffer)[index+1:]
+	}
+	// only the latest redraw of the current line is kept
+	*buffer = collapseCarriageReturns(*buffer)
+	if w.maxTokenSize > 0 && len(*buffer) > w.maxTokenSize {
+		w.writeToSinks(*buffer + "\n")
+		*buffer = ""
+	}
+}
+
+func (w *CmdWriter) flushForwarded(buffer *string) {
+	if len(*buffer) > 0 {
+		w.writeToSinks(strings.TrimSuffix(*buffer, "\r") + "\n")
+		*buffer = ""
+	}
+}
+
+// collapseCarriageReturns removes any content of the line that has been
+// redrawn by a carriage return.
+func collapseCarriageReturns(line string) string {
+	trimmed := strings.TrimSuffix(line, "\r")
+	index := strings.LastIndexByte(trimmed, '\r')
+	if index == -1 {
+		return line
+	}
+	return line[index+1:]
+}
+
+// TintStderr sets whether or not output proxied from stderr is colored red.
+func (w *CmdWriter) TintStderr(tint bool) {
+	w.tintStderr = tint
+}
+
+// AddSink registers an additional writer that receives each line of output.
+func (w *CmdWriter) AddSink(sink io.Writer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.sinks = append(w.sinks, sink)
+}
+
+func (w *CmdWriter) writeToSinks(line string) {
+	for _, sink := range w.sinks {
+		sink.Write([]byte(line))
+	}
+}
+
+// Proxy will forward the output from the provided reader through the writer.
+// The reader is closed once it is exhausted.
+func (w *CmdWriter) Proxy(r io.Reader) {
+	w.ProxyStreams(r, nil)
+}
+
+// ProxyStreams will forward the output from separate stdout and stderr
+// readers through the writer. The readers are closed once they are exhausted.
+func (w *CmdWriter) ProxyStreams(stdout io.Reader, stderr io.Reader) {
 	w.mu.Lock()
 	previousDone := w.done
 	w.mu.Unlock()
@@ -140,7 +318,43 @@ func (w *CmdWriter) Proxy(f *os.File) {
 		<-previousDone
 	}
 
-	scanner := bufio.NewScanner(f)
+	done := make(chan struct{})
+
+	w.mu.Lock()
+	w.done = done
+	w.mu.Unlock()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go w.scan(wg, stdout, w.write)
+	if stderr != nil {
+		wg.Add(1)
+		go w.scan(wg, stderr, w.writeStderr)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+}
+
+// Wait blocks until all proxied output has been written, or the timeout
+// elapses.
+func (w *CmdWriter) Wait(timeout time.Duration) {
+	w.mu.Lock()
+	done := w.done
+	w.mu.Unlock()
+
+	if done == nil {
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+func (w *CmdWriter) newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
 	maxTokenSize := w.maxTokenSize
 	if maxTokenSize <= 0 {
 		maxTokenSize = bufio.MaxScanTokenSize
@@ -150,31 +364,81 @@ func (w *CmdWriter) Proxy(f *os.File) {
 		initialSize = maxTokenSize
 	}
 	scanner.Buffer(make([]byte, 0, initialSize), maxTokenSize)
-	done := make(chan struct{})
+	return scanner
+}
 
-	w.mu.Lock()
-	w.done = done
-	w.mu.Unlock()
-	go func() {
-		defer close(done)
+func (w *CmdWriter) scan(wg *sync.WaitGroup, r io.Reader, write func([]byte) (int, error)) {
+	defer wg.Done()
+	if w.passingThrough() {
+		w.handleProxyErr(w.read(r, write))
+	} else {
+		scanner := w.newScanner(r)
 		for scanner.Scan() {
 			line := scanner.Text()
-			w.write([]byte(line + "\n"))
+			write([]byte(line + "\n"))
+		}
+		w.handleProxyErr(scanner.Err())
+	}
+	if closer, ok := r.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+func (w *CmdWriter) read(r io.Reader, write func([]byte) (int, error)) error {
+	buf := make([]byte, initialScanBufferSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			write(buf[:n])
+		}
+		if err == io.EOF {
+			return nil
 		}
-		err := scanner.Err()
 		if err != nil {
-			if err.Error() != ptyErr {
-				writeLineToKeepWithPrefix(w.name, w.file, "%s%s\n", color.HiRedString("proxy writer error: "), err.Error())
-				os.Exit(3)
-			}
+			return err
 		}
-	}()
+	}
+}
+
+func (w *CmdWriter) handleProxyErr(err error) {
+	if err != nil && !errors.Is(err, os.ErrClosed) {
+		if err.Error() != ptyErr {
+			w.console.WriteLine(prefixedLine(w.name, fmt.Sprintf("%s%s\n", color.HiRedString("proxy writer error: "), err.Error())))
+			os.Exit(3)
+		}
+	}
+}
+
+func (w *CmdWriter) writeStderr(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	line := string(p)
+	if w.passingThrough() {
+		if w.tintStderr {
+			line = color.RedString("%s", line)
+		}
+		w.forward(line, &w.stderrBuffer)
+		return len(p), nil
+	}
+	if w.tintStderr {
+		line = color.RedString("%s", strings.TrimSuffix(line, "\n")) + "\n"
+	}
+	w.writeLine(line, "stderr")
+	return len(p), nil
 }
 
 // Write implements the standard Write interface.
+func (w *CmdWriter) Write(p []byte) (int, error) {
+	return w.write(p)
+}
+
 func (w *CmdWriter) write(p []byte) (int, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	if w.passingThrough() {
+		w.forward(string(p), &w.buffer)
+		return len(p), nil
+	}
 	// append to buffer
 	w.buffer += string(p)
 	for {
@@ -183,7 +447,7 @@ func (w *CmdWriter) write(p []byte) (int, error) {
 			// no endline
 			break
 		}
-		writeLineToKeepWithoutPrefix(w.file, w.buffer[0:index+1])
+		w.writeLine(w.buffer[0:index+1], "stdout")
 		w.buffer = w.buffer[index+1:]
 	}
 	return len(p), nil
@@ -193,9 +457,30 @@ func (w *CmdWriter) write(p []byte) (int, error) {
 func (w *CmdWriter) Flush() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	if w.passingThrough() {
+		w.flushForwarded(&w.buffer)
+		w.flushForwarded(&w.stderrBuffer)
+		w.console.EndLine()
+		return nil
+	}
 	if len(w.buffer) > 0 {
-		writeLineToKeepWithoutPrefix(w.file, w.buffer+"\n")
+		w.writeLine(w.buffer+"\n", "stdout")
 		w.buffer = ""
 	}
+	w.flushGroup()
 	return nil
 }
+
+// Close flushes any remaining output. If the writer was returned by a mux, its
+// task is released so that its color and width no longer apply to others.
+func (w *CmdWriter) Close() error {
+	err := w.Flush()
+	w.mu.Lock()
+	closed := w.closed
+	w.closed = true
+	w.mu.Unlock()
+	if w.mux != nil && !closed {
+		w.mux.release(w.task)
+	}
+	return err
+}
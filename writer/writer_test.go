@@ -1,20 +1,24 @@
 package writer
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"os"
+	"reflect"
 	"runtime"
+	"sort"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/fatih/color"
 )
 
 func TestProxyReplacementDoesNotBlockProxyFlush(t *testing.T) {
-	output, err := os.CreateTemp(t.TempDir(), "witch-output-*")
-	if err != nil {
-		t.Fatalf("failed to create output file: %v", err)
-	}
-	defer output.Close()
+	output := &bytes.Buffer{}
 
-	cmdWriter := NewCmd("witch", output)
+	cmdWriter := NewCmd("witch", NewConsole(output))
 	cmdWriter.MaxTokenSize(1024)
 
 	oldReader, oldWriter, err := os.Pipe()
@@ -68,13 +72,9 @@ waiting:
 }
 
 func TestFlushWritesPartialLineOnce(t *testing.T) {
-	output, err := os.CreateTemp(t.TempDir(), "witch-output-*")
-	if err != nil {
-		t.Fatalf("failed to create output file: %v", err)
-	}
-	defer output.Close()
+	output := &bytes.Buffer{}
 
-	cmdWriter := NewCmd("witch", output)
+	cmdWriter := NewCmd("witch", NewConsole(output))
 	if _, err := cmdWriter.write([]byte("partial")); err != nil {
 		t.Fatalf("failed to buffer partial output: %v", err)
 	}
@@ -85,14 +85,189 @@ func TestFlushWritesPartialLineOnce(t *testing.T) {
 		t.Fatalf("failed to flush empty buffer: %v", err)
 	}
 
-	contents, err := os.ReadFile(output.Name())
-	if err != nil {
-		t.Fatalf("failed to read output file: %v", err)
-	}
-	if string(contents) != "partial\n" {
+	contents := output.String()
+	if contents != "partial\n" {
 		t.Fatalf("flush output = %q, want %q", contents, "partial\n")
 	}
 	if cmdWriter.buffer != "" {
 		t.Fatalf("buffer = %q, want empty", cmdWriter.buffer)
 	}
 }
+
+func TestProxyStreamsForwardsStdoutAndStderr(t *testing.T) {
+	output := &bytes.Buffer{}
+
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create stdout pipe: %v", err)
+	}
+	stderrReader, stderrWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create stderr pipe: %v", err)
+	}
+
+	oldNoColor := color.NoColor
+	color.NoColor = false
+	t.Cleanup(func() {
+		color.NoColor = oldNoColor
+	})
+
+	cmdWriter := NewCmd("witch", NewConsole(output))
+	cmdWriter.TintStderr(true)
+	cmdWriter.ProxyStreams(stdoutReader, stderrReader)
+
+	if _, err := stdoutWriter.WriteString("out\n"); err != nil {
+		t.Fatalf("failed to write stdout: %v", err)
+	}
+	stdoutWriter.Close()
+	if _, err := stderrWriter.WriteString("err\n"); err != nil {
+		t.Fatalf("failed to write stderr: %v", err)
+	}
+	stderrWriter.Close()
+
+	cmdWriter.Wait(time.Second)
+
+	// the streams are scanned concurrently, so their lines may interleave
+	// in either order
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	sort.Strings(lines)
+	want := []string{color.RedString("err"), "out"}
+	sort.Strings(want)
+	if !reflect.DeepEqual(lines, want) {
+		t.Fatalf("proxy output lines = %q, want %q in any order", lines, want)
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	got := StripANSI("\x1b[31mFAIL\x1b[0m main.go\x1b[2K\r")
+	if got != "FAIL main.go\r" {
+		t.Fatalf("StripANSI() = %q, want %q", got, "FAIL main.go\r")
+	}
+}
+
+func TestJSONFormatWrapsEntries(t *testing.T) {
+	output := &bytes.Buffer{}
+
+	console := NewConsole(output)
+	prettyWriter := NewPretty("witch", console)
+	prettyWriter.SetFormat(JSONFormat)
+	cmdWriter := NewCmd("witch", console)
+	cmdWriter.SetFormat(JSONFormat)
+
+	prettyWriter.WriteEntryf(Entry{Type: "file_event", Path: "main.go"}, "\x1b[34mmain.go\x1b[0m changed\n")
+	cmdWriter.Write([]byte("hello\n"))
+	cmdWriter.Flush()
+
+	contents := output.String()
+	lines := strings.Split(strings.TrimSpace(contents), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), contents)
+	}
+
+	var event Entry
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil {
+		t.Fatalf("failed to unmarshal entry: %v", err)
+	}
+	if event.Type != "file_event" || event.Path != "main.go" || event.Message != "main.go changed" {
+		t.Fatalf("unexpected entry: %+v", event)
+	}
+
+	var line Entry
+	if err := json.Unmarshal([]byte(lines[1]), &line); err != nil {
+		t.Fatalf("failed to unmarshal entry: %v", err)
+	}
+	if line.Type != "output" || line.Line == nil || *line.Line != "hello" || line.Stream != "stdout" {
+		t.Fatalf("unexpected entry: %+v", line)
+	}
+}
+
+func TestPassThroughForwardsPartialLines(t *testing.T) {
+	output := &bytes.Buffer{}
+	sink := &bytes.Buffer{}
+
+	cmdWriter := NewCmd("witch", NewConsole(output))
+	cmdWriter.PassThrough(true)
+	cmdWriter.AddSink(sink)
+
+	cmdWriter.Write([]byte("10%"))
+	if output.String() != "10%" {
+		t.Fatalf("output = %q, want %q", output.String(), "10%")
+	}
+	cmdWriter.Write([]byte("\r50%\r100%\n"))
+	cmdWriter.Write([]byte("Continue? "))
+	cmdWriter.Flush()
+
+	if got, want := output.String(), "10%\r50%\r100%\nContinue? \n"; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+	if got, want := sink.String(), "100%\nContinue? \n"; got != want {
+		t.Fatalf("sink output = %q, want %q", got, want)
+	}
+}
+
+func TestMuxPrefixesAndAlignsTasks(t *testing.T) {
+	withNoColor(t)
+
+	output := &bytes.Buffer{}
+	mux := NewMux(NewConsole(output))
+
+	short := mux.Writer("a")
+	long := mux.Writer("long")
+	short.Write([]byte("one\n"))
+	long.Write([]byte("two\n"))
+
+	if got, want := output.String(), "a    | one\nlong | two\n"; got != want {
+		t.Fatalf("mux output = %q, want %q", got, want)
+	}
+}
+
+func TestMuxReleasesClosedTasks(t *testing.T) {
+	withNoColor(t)
+
+	output := &bytes.Buffer{}
+	mux := NewMux(NewConsole(output))
+
+	for i := 0; i < 100; i++ {
+		mux.Writer(fmt.Sprintf("long-task-%d", i)).Close()
+	}
+	if len(mux.tasks) != 0 {
+		t.Fatalf("len(tasks) = %d after closing, want 0", len(mux.tasks))
+	}
+
+	a := mux.Writer("a")
+	b := mux.Writer("bb")
+	a.Write([]byte("one\n"))
+	b.Close()
+	b.Close()
+	a.Write([]byte("two\n"))
+	a.Close()
+
+	if got, want := output.String(), "a  | one\na | two\n"; got != want {
+		t.Fatalf("mux output = %q, want %q", got, want)
+	}
+}
+
+func TestGroupWritesBlockOnFlush(t *testing.T) {
+	output := &bytes.Buffer{}
+
+	cmdWriter := NewCmd("witch", NewConsole(output))
+	cmdWriter.Group(true)
+	cmdWriter.Write([]byte("one\ntwo\n"))
+	if output.Len() != 0 {
+		t.Fatalf("grouped output written before flush: %q", output.String())
+	}
+	cmdWriter.Flush()
+
+	if got, want := output.String(), "one\ntwo\n"; got != want {
+		t.Fatalf("grouped output = %q, want %q", got, want)
+	}
+}
+
+func withNoColor(t *testing.T) {
+	t.Helper()
+	oldNoColor := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() {
+		color.NoColor = oldNoColor
+	})
+}